@@ -0,0 +1,178 @@
+package ssgen
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/TwilightUncle/ssgen/features/md_parse"
+)
+
+// ビルドのどの段階で発生したエラーかを表す
+type Stage string
+
+const (
+	StageRead           Stage = "read"
+	StageFrontMatter    Stage = "front-matter"
+	StageMdMiddleware   Stage = "md middleware"
+	StageHtmlMiddleware Stage = "html middleware"
+	StageTemplate       Stage = "template"
+	StageLayout         Stage = "layout"
+	StageAsset          Stage = "asset"
+	StageWrite          Stage = "write"
+	StageSetup          Stage = "setup"
+)
+
+// ファイル単位のビルドエラー
+type BuildError struct {
+	// エラーの発生したファイル。テンプレートの実行エラーの場合はテンプレートのパス
+	Path string
+	// 変換対象のページのマークダウンのパス
+	Page  string
+	Stage Stage
+	// Pathにおける行番号。不明な場合は0
+	Line int
+	Err  error
+}
+
+func (e *BuildError) Error() string {
+	location := e.Path
+	if e.Line > 0 {
+		location += ":" + strconv.Itoa(e.Line)
+	}
+	if e.Page != "" && e.Page != e.Path {
+		location += " (" + e.Page + ")"
+	}
+	return fmt.Sprintf("%s: %s: %v", location, e.Stage, e.Err)
+}
+
+func (e *BuildError) Unwrap() error {
+	return e.Err
+}
+
+// ビルド全体で発生したエラーと警告の一覧
+type BuildReport struct {
+	Errors []*BuildError
+	// ビルドは継続される問題(リンク先の見つからない独自記法のリンク等)
+	Warnings []*BuildError
+}
+
+func (r *BuildReport) Error() string {
+	noun := "errors"
+	if len(r.Errors) == 1 {
+		noun = "error"
+	}
+	lines := []string{fmt.Sprintf("build failed with %d %s:", len(r.Errors), noun)}
+	for _, e := range r.Errors {
+		lines = append(lines, "  "+e.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// 個々のエラー。errors.Asにより先頭のBuildErrorを取得できる
+func (r *BuildReport) Unwrap() []error {
+	errs := make([]error, 0, len(r.Errors))
+	for _, e := range r.Errors {
+		errs = append(errs, e)
+	}
+	return errs
+}
+
+// エラーを追加する。BuildError以外のエラーはpathとstageを補ってBuildErrorとする
+func (r *BuildReport) add(err error, path string, stage Stage) {
+	var report *BuildReport
+	if errors.As(err, &report) {
+		r.Errors = append(r.Errors, report.Errors...)
+		return
+	}
+	var buildErr *BuildError
+	if errors.As(err, &buildErr) {
+		r.Errors = append(r.Errors, buildErr)
+		return
+	}
+	r.Errors = append(r.Errors, &BuildError{Path: path, Page: path, Stage: stage, Err: err})
+}
+
+// ページの問題を追加する。strictの場合はエラーとして追加する
+func (r *BuildReport) addWarnings(path string, warnings []md_parse.Warning, strict bool) {
+	for _, w := range warnings {
+		stage := StageMdMiddleware
+		if w.Source == md_parse.WarningSourceFrontMatter {
+			stage = StageFrontMatter
+		}
+		e := &BuildError{Path: path, Page: path, Stage: stage, Line: w.Line, Err: errors.New(w.Message)}
+		if strict {
+			r.Errors = append(r.Errors, e)
+		} else {
+			r.Warnings = append(r.Warnings, e)
+		}
+	}
+}
+
+// 警告の一覧を文字列で返す
+func (r *BuildReport) WarningSummary() string {
+	noun := "warnings"
+	if len(r.Warnings) == 1 {
+		noun = "warning"
+	}
+	lines := []string{fmt.Sprintf("%d %s:", len(r.Warnings), noun)}
+	for _, w := range r.Warnings {
+		lines = append(lines, "  "+w.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// エラーが存在しない場合はnilを返す
+func (r *BuildReport) err() error {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+	return r
+}
+
+// html/templateのエラー中の行番号 (template: name:line:col: ...)
+var templateErrLinePattern = regexp.MustCompile(`template: [^:]+:(\d+)`)
+
+// エラーメッセージから行番号を抽出する。見つからない場合は0
+func findErrLine(err error, pattern *regexp.Regexp) int {
+	match := pattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return 0
+	}
+	line, _ := strconv.Atoi(match[1])
+	return line
+}
+
+// マークダウンの変換時のエラーを生成
+// スキーマへの違反は、違反ごとのエラーを持つ*BuildReportとする
+func newPageError(mdPath string, stage Stage, err error) error {
+	var schemaErr *md_parse.SchemaError
+	if errors.As(err, &schemaErr) {
+		report := &BuildReport{}
+		for _, v := range schemaErr.Violations {
+			report.Errors = append(report.Errors, &BuildError{Path: mdPath, Page: mdPath, Stage: stage, Line: v.Line, Err: v})
+		}
+		return report
+	}
+
+	line := 0
+	var lineErr *md_parse.LineError
+	if errors.As(err, &lineErr) {
+		line = lineErr.Line
+		err = lineErr.Err
+	}
+	return &BuildError{Path: mdPath, Page: mdPath, Stage: stage, Line: line, Err: err}
+}
+
+// テンプレートの解析、実行時のエラーを生成
+func newTemplateError(templatePath string, mdPath string, err error) *BuildError {
+	return &BuildError{
+		Path:  templatePath,
+		Page:  mdPath,
+		Stage: StageTemplate,
+		Line:  findErrLine(err, templateErrLinePattern),
+		Err:   err,
+	}
+}
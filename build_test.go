@@ -0,0 +1,263 @@
+package ssgen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 出力済みのファイルを上書きする目印。次のビルドで出力し直されなかったファイルはこの内容のまま残る
+const outputMarker = "not rewritten"
+
+// 設定からサイトを生成して出力する
+func buildTestSite(t *testing.T, opts Options) error {
+	site, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	site.Log = io.Discard
+	return site.Build()
+}
+
+// 出力先のHTMLファイルを全て目印で上書きする
+func markOutputs(t *testing.T, outputDir string) {
+	for _, page := range outputPages(t, outputDir) {
+		writeTestFile(t, filepath.Join(outputDir, page), outputMarker)
+	}
+}
+
+// markOutputs以降に出力し直されたHTMLファイルの一覧
+func rewrittenPages(t *testing.T, outputDir string) []string {
+	pages := []string{}
+	for _, page := range outputPages(t, outputDir) {
+		if readTestFile(t, filepath.Join(outputDir, page)) != outputMarker {
+			pages = append(pages, page)
+		}
+	}
+	return pages
+}
+
+func TestBuildIncremental(t *testing.T) {
+	opts := makeTestSite(t, map[string]string{
+		"index.md":      "# Home\n",
+		"about.md":      "---\ntitle: About\n---\n# About\n",
+		"guide/page.md": "# Page\n",
+	})
+	opts.CachePath = filepath.Join(opts.OutputDir, ".ssgen-cache.json")
+	mdDir := opts.MdBaseDir
+	all := []string{"about.html", "guide/page.html", "index.html"}
+
+	steps := []struct {
+		name   string
+		change func()
+		// 出力し直されるページ
+		rewritten []string
+		// 残っているページ
+		pages   []string
+		wantErr bool
+	}{
+		{name: "first build", change: func() {}, rewritten: all, pages: all},
+		{name: "no change", change: func() {}, rewritten: []string{}, pages: all},
+		// 本文の変更はそのページのみ
+		{
+			name:      "edit body",
+			change:    func() { writeTestFile(t, filepath.Join(mdDir, "about.md"), "---\ntitle: About\n---\n# About us\n") },
+			rewritten: []string{"about.html"},
+			pages:     all,
+		},
+		// タイトルはナビゲーション等により全てのページに影響する
+		{
+			name:      "edit title",
+			change:    func() { writeTestFile(t, filepath.Join(mdDir, "about.md"), "---\ntitle: About us\n---\n# About us\n") },
+			rewritten: all,
+			pages:     all,
+		},
+		// 失敗したページは前回の出力を残し、元の内容に戻した場合も次回に出力し直す
+		{
+			name: "broken page",
+			change: func() {
+				opts.KeepGoing = true
+				writeTestFile(t, filepath.Join(mdDir, "about.md"), "---\ntitle: About us\n---\n# About us\n[{up|../index}]\n")
+			},
+			rewritten: []string{},
+			pages:     all,
+			wantErr:   true,
+		},
+		{
+			name:      "fixed page",
+			change:    func() { writeTestFile(t, filepath.Join(mdDir, "about.md"), "---\ntitle: About us\n---\n# About us\n") },
+			rewritten: []string{"about.html"},
+			pages:     all,
+		},
+		// 削除されたページの出力のみ取り除く。ページの一覧はナビゲーション等により全てのページに影響する
+		{
+			name: "delete page",
+			change: func() {
+				if err := os.Remove(filepath.Join(mdDir, "guide", "page.md")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			rewritten: []string{"about.html", "index.html"},
+			pages:     []string{"about.html", "index.html"},
+		},
+		// 目次の深さは全てのページに影響する
+		{
+			name:      "toc depth",
+			change:    func() { opts.TOCMaxDepth = 2 },
+			rewritten: []string{"about.html", "index.html"},
+			pages:     []string{"about.html", "index.html"},
+		},
+	}
+
+	// 出力先のディレクトリを作り直していないことの確認に用いる
+	keep := filepath.Join(opts.OutputDir, "keep.txt")
+	for i, step := range steps {
+		step.change()
+		if err := buildTestSite(t, opts); (err != nil) != step.wantErr {
+			t.Fatalf("%s: Actual [%v], want error: %v", step.name, err, step.wantErr)
+		}
+		if i == 0 {
+			writeTestFile(t, keep, "")
+		} else if _, err := os.Stat(keep); err != nil {
+			t.Errorf("%s: output directory was recreated: %v", step.name, err)
+		}
+
+		if actual := rewrittenPages(t, opts.OutputDir); !reflect.DeepEqual(actual, step.rewritten) {
+			t.Errorf("%s: rewritten Actual [%s], want [%s]", step.name, strings.Join(actual, ", "), strings.Join(step.rewritten, ", "))
+		}
+		if actual := outputPages(t, opts.OutputDir); !reflect.DeepEqual(actual, step.pages) {
+			t.Errorf("%s: pages Actual [%s], want [%s]", step.name, strings.Join(actual, ", "), strings.Join(step.pages, ", "))
+		}
+		markOutputs(t, opts.OutputDir)
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	for _, keepGoing := range []bool{false, true} {
+		for _, concurrency := range []int{1, 8} {
+			opts := makeTestSite(t, map[string]string{
+				"a.md":     "# A\n",
+				"b.md":     "---\ntitle: [\n---\n# B\n",
+				"c.md":     "# C\n",
+				"sub/d.md": "---\ntitle: D\nweight: [\n---\n# D\n",
+				"sub/e.md": "# E\n",
+			})
+			opts.KeepGoing = keepGoing
+			opts.Concurrency = concurrency
+			name := fmt.Sprintf("keepGoing=%v concurrency=%d", keepGoing, concurrency)
+
+			err := buildTestSite(t, opts)
+			var report *BuildReport
+			if !errors.As(err, &report) {
+				t.Fatalf("%s: Actual [%v], want *BuildReport", name, err)
+			}
+			// ワーカー数によらずページの順序で並ぶ
+			actual := []string{}
+			for _, e := range report.Errors {
+				rel, _ := filepath.Rel(opts.MdBaseDir, e.Path)
+				actual = append(actual, fmt.Sprintf("%s:%d %s", filepath.ToSlash(rel), e.Line, e.Stage))
+			}
+			want := []string{"b.md:2 front-matter", "sub/d.md:3 front-matter"}
+			if !reflect.DeepEqual(actual, want) {
+				t.Errorf("%s: errors Actual [%s], want [%s]", name, strings.Join(actual, ", "), strings.Join(want, ", "))
+			}
+
+			// KeepGoingでなければ一つも書き込まない
+			wantPages := []string{}
+			if keepGoing {
+				wantPages = []string{"a.html", "c.html", "sub/e.html"}
+			}
+			if pages := outputPages(t, opts.OutputDir); !reflect.DeepEqual(pages, wantPages) {
+				t.Errorf("%s: pages Actual [%s], want [%s]", name, strings.Join(pages, ", "), strings.Join(wantPages, ", "))
+			}
+		}
+	}
+}
+
+func TestBuildDrafts(t *testing.T) {
+	pages := map[string]string{
+		"index.md":  "# Home\n\n[{secret}]\n",
+		"secret.md": "---\ndraft: true\n---\n# Secret\n",
+	}
+	cases := []struct {
+		mode    Mode
+		drafts  DraftsMode
+		include bool
+	}{
+		{mode: ModeBuild, drafts: DraftsAuto, include: false},
+		{mode: ModeBuild, drafts: DraftsExclude, include: false},
+		{mode: ModeBuild, drafts: DraftsInclude, include: true},
+		// プレビューでは既定で含める
+		{mode: ModePreview, drafts: DraftsAuto, include: true},
+		{mode: ModePreview, drafts: DraftsExclude, include: false},
+	}
+	for _, c := range cases {
+		name := fmt.Sprintf("%s drafts=%d", c.mode, c.drafts)
+		opts := makeTestSite(t, pages)
+		opts.Mode = c.mode
+		opts.Drafts = c.drafts
+		site, err := New(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// プレビューはサーバーを起動せず、ページを直接レンダリングする
+		var warnings []string
+		var index string
+		if c.mode == ModePreview {
+			tmpl, err := site.loadTemplate()
+			if err != nil {
+				t.Fatal(err)
+			}
+			html, pageWarnings, err := site.renderPage(tmpl, filepath.Join(opts.MdBaseDir, "index.md"))
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			index = string(html)
+			for _, w := range pageWarnings {
+				warnings = append(warnings, w.Message)
+			}
+		} else {
+			var log bytes.Buffer
+			site.Log = &log
+			if err := site.Build(); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			contents, err := os.ReadFile(filepath.Join(opts.OutputDir, "index.html"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			index = string(contents)
+			if log.Len() > 0 {
+				warnings = append(warnings, log.String())
+			}
+
+			wantPages := []string{"index.html"}
+			if c.include {
+				wantPages = []string{"index.html", "secret.html"}
+			}
+			if actual := outputPages(t, opts.OutputDir); !reflect.DeepEqual(actual, wantPages) {
+				t.Errorf("%s: pages Actual [%s], want [%s]", name, strings.Join(actual, ", "), strings.Join(wantPages, ", "))
+			}
+		}
+
+		// 除外したページはナビゲーションに現れず、そのページへのリンクは警告となる
+		wantNav := "<nav>[index]</nav>"
+		if c.include {
+			wantNav = "<nav>[index][secret]</nav>"
+		}
+		if !strings.Contains(index, wantNav) {
+			t.Errorf("%s: Actual [%s], want nav %s", name, index, wantNav)
+		}
+		unresolved := len(warnings) == 1 && strings.Contains(warnings[0], "unresolved link [{secret}]")
+		if c.include && len(warnings) > 0 || !c.include && !unresolved {
+			t.Errorf("%s: warnings Actual [%s], want unresolved link: %v", name, strings.Join(warnings, ", "), !c.include)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/TwilightUncle/ssgen"
+	"github.com/TwilightUncle/ssgen/features/link_check"
+	"github.com/TwilightUncle/ssgen/features/scaffold"
+)
+
+const usage = `usage: ssgen <command> [flags]
+
+commands:
+  build         build the static site into the output directory
+  serve         run the preview server
+  serve-static  build the static site and serve the output directory
+  new <dir>     create a new site skeleton in <dir>
+  check         convert all pages without writing the output, then check
+                the links of the HTML in the output directory
+
+Run 'ssgen <command> -h' for the flags of each command.
+
+The config file (ssgen.yaml, ssgen.yml, ssgen.toml or ssgen.json) in the
+working directory is loaded automatically. Flags take precedence over it.
+`
+
+// 一度の実行における、コマンド固有のフラグの値と出力先
+// runを複数回呼び出せるよう、実行ごとに生成する
+type invocation struct {
+	// checkの結果の出力形式(text, json)
+	checkFormat string
+	// checkの結果の出力先
+	checkOutput io.Writer
+}
+
+// サブコマンドの処理
+type command func(inv *invocation, opts ssgen.Options, args []string) error
+
+var commands = map[string]command{
+	"build":        runSite(ssgen.ModeBuild),
+	"serve":        runSite(ssgen.ModePreview),
+	"serve-static": runSite(ssgen.ModePreviewStatic),
+	"new":          runNew,
+	"check":        runCheck,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// サブコマンドを解釈して実行し、終了コードを返す
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	name := args[0]
+	if name == "-h" || name == "-help" || name == "--help" || name == "help" {
+		fmt.Fprint(stderr, usage)
+		return 0
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "ssgen: unknown command %q\n\n%s", name, usage)
+		return 2
+	}
+
+	// Default関数の引数に対応するフラグ
+	inv := &invocation{checkOutput: stdout}
+	opts := defaultOptions()
+	fs := flag.NewFlagSet("ssgen "+name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	opts.BindSiteFlags(fs)
+	inv.bindCommandFlags(name, fs)
+	configPath := fs.String("config", "", "path of the config file (default: searched in the working directory)")
+	env := fs.String("env", defaultEnv(name), "environment name of the overrides in the config file")
+	if err := fs.Parse(args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	if name != "new" {
+		if err := loadConfig(&opts, fs, *configPath, *env); err != nil {
+			fmt.Fprintf(stderr, "ssgen %s: %v\n", name, err)
+			return 1
+		}
+	}
+
+	if err := cmd(inv, opts, fs.Args()); err != nil {
+		fmt.Fprintf(stderr, "ssgen %s: %v\n", name, err)
+		return 1
+	}
+	return 0
+}
+
+// フラグ未指定時の設定
+func defaultOptions() ssgen.Options {
+	return ssgen.Options{
+		BaseUrl:     "",
+		MdBaseDir:   "md",
+		AssetsPath:  "assets",
+		TemplateDir: "templates",
+		OutputDir:   "public",
+		CachePath:   ".ssgen-cache",
+	}
+}
+
+// 設定ファイルの環境名の既定値。SSGEN_ENV が優先
+func defaultEnv(name string) string {
+	if env := os.Getenv("SSGEN_ENV"); env != "" {
+		return env
+	}
+	if name == "serve" {
+		return "development"
+	}
+	return "production"
+}
+
+// 設定ファイルを読み込む。明示的に指定されたフラグは設定ファイルより優先する
+func loadConfig(opts *ssgen.Options, fs *flag.FlagSet, path string, env string) error {
+	// 設定ファイルにより上書きされる前に、指定されたフラグの値を退避
+	specified := map[string]string{}
+	fs.Visit(func(f *flag.Flag) {
+		specified[f.Name] = f.Value.String()
+	})
+
+	if err := opts.LoadConfig(path, env); err != nil {
+		return err
+	}
+
+	for name, value := range specified {
+		if err := fs.Set(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 指定のモードでサイトを構築する処理を返す
+func runSite(mode ssgen.Mode) command {
+	return func(inv *invocation, opts ssgen.Options, args []string) error {
+		opts.Mode = mode
+		site, err := ssgen.New(opts)
+		if err != nil {
+			return err
+		}
+		if mode == ssgen.ModeBuild {
+			return site.BuildStaticSite()
+		}
+
+		// 割り込みによりサーバーを停止する
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return site.Serve(ctx)
+	}
+}
+
+// サイトの雛形を作成する
+func runNew(inv *invocation, opts ssgen.Options, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("directory is required")
+	}
+	return scaffold.Create(args[0], opts.MdBaseDir, opts.AssetsPath, opts.TemplateDir)
+}
+
+// コマンド固有のフラグを紐づける
+func (inv *invocation) bindCommandFlags(name string, fs *flag.FlagSet) {
+	switch name {
+	case "check":
+		fs.StringVar(&inv.checkFormat, "format", "text", "output format of the link check (text or json)")
+	}
+}
+
+// 出力を行わずに全ページの変換を確認し、出力ディレクトリのリンクを検査する
+func runCheck(inv *invocation, opts ssgen.Options, args []string) error {
+	if inv.checkFormat != "text" && inv.checkFormat != "json" {
+		return fmt.Errorf("unknown format %q", inv.checkFormat)
+	}
+
+	site, err := ssgen.New(opts)
+	if err != nil {
+		return err
+	}
+	if err := site.Check(); err != nil {
+		return err
+	}
+
+	problems, err := site.CheckLinks()
+	if err != nil {
+		return err
+	}
+	if err := printProblems(inv.checkOutput, inv.checkFormat, problems); err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("found %d broken link(s)", len(problems))
+	}
+	return nil
+}
+
+// リンクの検査結果を指定の形式で出力する
+func printProblems(w io.Writer, format string, problems []link_check.Problem) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(problems)
+	}
+	for _, p := range problems {
+		if _, err := fmt.Fprintln(w, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TwilightUncle/ssgen/features/link_check"
+)
+
+// テストの間のみ、作業ディレクトリを一時ディレクトリへ移す
+func chdirTemp(t *testing.T) string {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := os.Chdir(wd); err != nil {
+			t.Fatal(err)
+		}
+	})
+	return dir
+}
+
+// 作業ディレクトリに雛形のサイトを作成し、出力する
+func buildNewSite(t *testing.T) {
+	for _, args := range [][]string{{"new", "."}, {"build"}} {
+		var stderr bytes.Buffer
+		if code := run(args, &bytes.Buffer{}, &stderr); code != 0 {
+			t.Fatalf("%s: Actual [%d %s], want 0", args[0], code, stderr.String())
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := chdirTemp(t)
+	cases := []struct {
+		args []string
+		code int
+	}{
+		{args: []string{}, code: 2},
+		{args: []string{"help"}, code: 0},
+		{args: []string{"unknown"}, code: 2},
+		{args: []string{"build", "-unknown"}, code: 2},
+		{args: []string{"build", "-h"}, code: 0},
+		// マークダウンのディレクトリが無い
+		{args: []string{"build"}, code: 1},
+		{args: []string{"check"}, code: 1},
+		{args: []string{"new"}, code: 1},
+		{args: []string{"new", "."}, code: 0},
+		// 既に存在するファイルは上書きしない
+		{args: []string{"new", "."}, code: 1},
+		{args: []string{"build"}, code: 0},
+		{args: []string{"check"}, code: 0},
+		{args: []string{"check", "-format", "xml"}, code: 1},
+	}
+	// 同一プロセスで繰り返し実行できる
+	for _, c := range cases {
+		var stdout, stderr bytes.Buffer
+		if code := run(c.args, &stdout, &stderr); code != c.code {
+			t.Errorf("%v: Actual [%d %s], want %d", c.args, code, stderr.String(), c.code)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "public", "index.html")); err != nil {
+		t.Errorf("want the built page: %v", err)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ssgen.yaml")
+	config := "mdBaseDir: config-md\noutputDir: config-out\nassetsPath: config-assets\n"
+	if err := os.WriteFile(path, []byte(config), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := defaultOptions()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	opts.BindSiteFlags(fs)
+	// 既定値と同じ値であっても、指定されたフラグは設定ファイルより優先する
+	if err := fs.Parse([]string{"-output", "flag-out", "-md-dir", "md"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadConfig(&opts, fs, path, "production"); err != nil {
+		t.Fatal(err)
+	}
+
+	if opts.OutputDir != "flag-out" || opts.MdBaseDir != "md" {
+		t.Errorf("Actual [%s %s], want the flag values", opts.OutputDir, opts.MdBaseDir)
+	}
+	if opts.AssetsPath != "config-assets" {
+		t.Errorf("Actual [%s], want the config value", opts.AssetsPath)
+	}
+}
+
+func TestCheckJSON(t *testing.T) {
+	dir := chdirTemp(t)
+	buildNewSite(t)
+	broken := `<html><body><a href="/missing.html">missing</a></body></html>`
+	if err := os.WriteFile(filepath.Join(dir, "public", "broken.html"), []byte(broken), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"check", "-format", "json"}, &stdout, &stderr); code != 1 {
+		t.Errorf("Actual [%d %s], want 1", code, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "found 1 broken link(s)") {
+		t.Errorf("Actual [%s], want the number of broken links", stderr.String())
+	}
+
+	// 結果はcheckOutputへJSONで出力する
+	var problems []link_check.Problem
+	if err := json.Unmarshal(stdout.Bytes(), &problems); err != nil {
+		t.Fatalf("Actual [%s], want json: %v", stdout.String(), err)
+	}
+	want := link_check.Problem{File: "broken.html", Line: 1, Kind: link_check.KindDeadLink, Attr: "href", Url: "/missing.html"}
+	if len(problems) != 1 || problems[0] != want {
+		t.Errorf("Actual [%+v], want [%+v]", problems, want)
+	}
+}
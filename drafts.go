@@ -0,0 +1,75 @@
+package ssgen
+
+import (
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/TwilightUncle/ssgen/features/access_md"
+	"github.com/TwilightUncle/ssgen/features/auto_link"
+	"github.com/TwilightUncle/ssgen/features/md_parse"
+)
+
+// 公開されないページ(下書き、公開日時前、有効期限後)の扱い
+type DraftsMode int
+
+const (
+	// ModePreviewでは含め、それ以外では除外する
+	DraftsAuto DraftsMode = iota
+	// 常に含める
+	DraftsInclude
+	// 常に除外する
+	DraftsExclude
+)
+
+// 公開されないページを含めるか
+func (s *Site) includeDrafts() bool {
+	if s.Drafts == DraftsAuto {
+		return s.Mode == ModePreview
+	}
+	return s.Drafts == DraftsInclude
+}
+
+// 公開されないページのパスと、その理由を返す
+// メタデータは収集済みの見出し情報のものを用いる。フロントマターを読み取れないページは公開するものとし、エラーはページの変換時に報告する
+func unpublishedPages(mdPaths access_md.MdPaths, allHInfos auto_link.MdAllHeaaderInfo, now time.Time) map[string]string {
+	pages := map[string]md_parse.MetaData{}
+	for _, metaData := range allHInfos.Pages() {
+		pages[metaData.PageName] = metaData
+	}
+
+	unpublished := map[string]string{}
+	for _, mdPath := range mdPaths.GetAll() {
+		metaData := pages[filepath.ToSlash(mdPaths.GetPageName(mdPath))]
+		if reason := metaData.UnpublishedReason(now); reason != "" {
+			unpublished[mdPath] = reason
+		}
+	}
+	return unpublished
+}
+
+// -drafts を真偽値フラグとして扱う。未指定の場合はDraftsAutoのまま
+type draftsFlag struct {
+	target *DraftsMode
+}
+
+func (f draftsFlag) IsBoolFlag() bool {
+	return true
+}
+
+func (f draftsFlag) String() string {
+	return strconv.FormatBool(f.target != nil && *f.target == DraftsInclude)
+}
+
+func (f draftsFlag) Set(value string) error {
+	include, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	if include {
+		*f.target = DraftsInclude
+	} else {
+		*f.target = DraftsExclude
+	}
+	return nil
+}
@@ -53,6 +53,18 @@ func (p *MdPaths) GetPageDir(path string) string {
 	return dir
 }
 
+// fnが真を返すファイルパスのみを保持するMdPathsを返却
+func (p MdPaths) Filter(fn func(fPath string) bool) MdPaths {
+	paths := []string{}
+	for _, path := range p.paths {
+		if fn(path) {
+			paths = append(paths, path)
+		}
+	}
+	p.paths = paths
+	return p
+}
+
 // 全要素に関数を適用した結果スライスを返却
 func (p *MdPaths) Map(fn func(fPath string) interface{}) []interface{} {
 	result := []interface{}{}
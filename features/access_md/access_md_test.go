@@ -51,3 +51,17 @@ func TestNewMdPaths(t *testing.T) {
 	// Map()
 	// MapFileContent()
 }
+
+func TestFilter(t *testing.T) {
+	mdPaths := MdPaths{baseDir: "md", paths: []string{"md/a.md", "md/b.md", "md/c.md"}}
+	filtered := mdPaths.Filter(func(fPath string) bool {
+		return fPath != "md/b.md"
+	})
+	if want := []string{"md/a.md", "md/c.md"}; !slices.Equal(filtered.GetAll(), want) {
+		t.Errorf("Actual [%v], want [%v]", filtered.GetAll(), want)
+	}
+	// 元のMdPathsは変更しない
+	if len(mdPaths.GetAll()) != 3 || filtered.GetBaseDirPath() != "md" {
+		t.Errorf("Actual [%v], want unchanged", mdPaths.GetAll())
+	}
+}
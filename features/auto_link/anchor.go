@@ -0,0 +1,185 @@
+package auto_link
+
+import (
+	"bytes"
+	"html/template"
+	"strings"
+
+	"golang.org/x/exp/slices"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// 見出しへ挿入するパーマリンクの既定の要素
+const DefaultAnchorTemplate = `<a class="anchor" href="#{{.Id}}" aria-label="Permalink">¶</a>`
+
+// 見出しへのパーマリンクの挿入方法
+type AnchorOptions struct {
+	// 挿入する要素のhtml/template。{{.Id}}, {{.Text}}で見出しのid、内容を参照できる
+	// 空の場合はDefaultAnchorTemplate
+	Template string
+	// 挿入する見出しの深さ(1～6)。空の場合は全ての見出し
+	Depths []int
+	// 真の場合は見出しの内容の前、偽の場合は後ろへ挿入する
+	Prepend bool
+	// 内側の見出しには挿入しない要素。要素名(blockquote)もしくはクラス名(.no-anchor)で指定する
+	SkipContainers []string
+}
+
+// テンプレートへ渡す見出しの情報
+type anchorData struct {
+	Id   string
+	Text string
+}
+
+// 開いている要素
+type openElement struct {
+	name    string
+	classes []string
+}
+
+// id属性を持つh1～h6へパーマリンクを挿入する
+// idを持たない見出しはそのままとするため、AddIdForHtmlHの後に実行すること
+func AddAnchorForHtmlH(htmlStr string, opts AnchorOptions) (string, error) {
+	src := opts.Template
+	if src == "" {
+		src = DefaultAnchorTemplate
+	}
+	t, err := template.New("anchor").Parse(src)
+	if err != nil {
+		return htmlStr, err
+	}
+
+	var out bytes.Buffer
+	var stack []openElement
+	tokenizer := html.NewTokenizer(strings.NewReader(htmlStr))
+	// 処理中の見出し。挿入対象でない場合は空
+	var headingTag string
+	var heading anchorData
+	var headingText strings.Builder
+	var headingInner bytes.Buffer
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+		// Tokenは内部のバッファを書き換えるため、先に複製する
+		raw := append([]byte(nil), tokenizer.Raw()...)
+		token := tokenizer.Token()
+
+		switch tokenType {
+		case html.StartTagToken:
+			if headingTag == "" && anchorTarget(token, stack, opts) {
+				headingTag = token.Data
+				heading = anchorData{Id: attrValue(token, "id")}
+				headingText.Reset()
+				headingInner.Reset()
+				out.Write(raw)
+				continue
+			}
+			if !isVoidElement(token.DataAtom) {
+				stack = append(stack, openElement{name: token.Data, classes: strings.Fields(attrValue(token, "class"))})
+			}
+		case html.EndTagToken:
+			if token.Data == headingTag {
+				heading.Text = strings.TrimSpace(headingText.String())
+				var anchor bytes.Buffer
+				if err := t.Execute(&anchor, heading); err != nil {
+					return htmlStr, err
+				}
+				if opts.Prepend {
+					out.Write(anchor.Bytes())
+					out.Write(headingInner.Bytes())
+				} else {
+					out.Write(headingInner.Bytes())
+					out.Write(anchor.Bytes())
+				}
+				out.Write(raw)
+				headingTag = ""
+				headingInner.Reset()
+				continue
+			}
+			stack = popElement(stack, token.Data)
+		case html.TextToken:
+			if headingTag != "" {
+				headingText.WriteString(token.Data)
+			}
+		}
+
+		if headingTag != "" {
+			headingInner.Write(raw)
+			continue
+		}
+		out.Write(raw)
+	}
+	// 見出しが閉じられていない場合
+	out.Write(headingInner.Bytes())
+	return out.String(), nil
+}
+
+// パーマリンクを挿入する見出しか
+func anchorTarget(token html.Token, stack []openElement, opts AnchorOptions) bool {
+	depth := headingDepth(token.DataAtom)
+	if depth == 0 || attrValue(token, "id") == "" {
+		return false
+	}
+	if len(opts.Depths) > 0 && !slices.Contains(opts.Depths, depth) {
+		return false
+	}
+	for _, e := range stack {
+		for _, container := range opts.SkipContainers {
+			if container == e.name || (strings.HasPrefix(container, ".") && slices.Contains(e.classes, container[1:])) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// 見出しの深さ。見出しでない場合は0
+func headingDepth(a atom.Atom) int {
+	switch a {
+	case atom.H1:
+		return 1
+	case atom.H2:
+		return 2
+	case atom.H3:
+		return 3
+	case atom.H4:
+		return 4
+	case atom.H5:
+		return 5
+	case atom.H6:
+		return 6
+	}
+	return 0
+}
+
+// 終了タグを持たない要素か
+func isVoidElement(a atom.Atom) bool {
+	switch a {
+	case atom.Area, atom.Base, atom.Br, atom.Col, atom.Embed, atom.Hr, atom.Img, atom.Input,
+		atom.Link, atom.Meta, atom.Source, atom.Track, atom.Wbr:
+		return true
+	}
+	return false
+}
+
+// 終了タグに対応する要素まで閉じる。対応する要素が無い場合は何もしない
+func popElement(stack []openElement, name string) []openElement {
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i].name == name {
+			return stack[:i]
+		}
+	}
+	return stack
+}
+
+func attrValue(token html.Token, key string) string {
+	for _, attr := range token.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
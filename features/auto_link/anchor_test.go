@@ -0,0 +1,54 @@
+package auto_link
+
+import "testing"
+
+const anchorHtml = `<h1 id="top">Top</h1>
+<p>text<br>more</p>
+<h2>No id</h2>
+<h3 id="code"><code>a &amp; b</code></h3>
+<blockquote><h2 id="quoted">Quoted</h2></blockquote>
+<div class="card no-anchor"><h2 id="card">Card</h2></div>
+<h2 id="after">After</h2>`
+
+func TestAddAnchorForHtmlH(t *testing.T) {
+	actual, err := AddAnchorForHtmlH(anchorHtml, AnchorOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<h1 id="top">Top<a class="anchor" href="#top" aria-label="Permalink">¶</a></h1>
+<p>text<br>more</p>
+<h2>No id</h2>
+<h3 id="code"><code>a &amp; b</code><a class="anchor" href="#code" aria-label="Permalink">¶</a></h3>
+<blockquote><h2 id="quoted">Quoted<a class="anchor" href="#quoted" aria-label="Permalink">¶</a></h2></blockquote>
+<div class="card no-anchor"><h2 id="card">Card<a class="anchor" href="#card" aria-label="Permalink">¶</a></h2></div>
+<h2 id="after">After<a class="anchor" href="#after" aria-label="Permalink">¶</a></h2>`
+	if actual != want {
+		t.Errorf("Actual [%s], want [%s]", actual, want)
+	}
+}
+
+func TestAddAnchorForHtmlHWithOptions(t *testing.T) {
+	actual, err := AddAnchorForHtmlH(anchorHtml, AnchorOptions{
+		Template:       `<a href="#{{.Id}}" title="{{.Text}}">#</a>`,
+		Depths:         []int{2, 3},
+		Prepend:        true,
+		SkipContainers: []string{"blockquote", ".no-anchor"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<h1 id="top">Top</h1>
+<p>text<br>more</p>
+<h2>No id</h2>
+<h3 id="code"><a href="#code" title="a &amp; b">#</a><code>a &amp; b</code></h3>
+<blockquote><h2 id="quoted">Quoted</h2></blockquote>
+<div class="card no-anchor"><h2 id="card">Card</h2></div>
+<h2 id="after"><a href="#after" title="After">#</a>After</h2>`
+	if actual != want {
+		t.Errorf("Actual [%s], want [%s]", actual, want)
+	}
+
+	if _, err := AddAnchorForHtmlH(anchorHtml, AnchorOptions{Template: "{{.Id"}); err == nil {
+		t.Errorf("want error for invalid template")
+	}
+}
@@ -1,12 +1,15 @@
 package auto_link
 
 import (
+	"errors"
 	"fmt"
-	"net/url"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/TwilightUncle/ssgen/features/access_md"
+	"github.com/TwilightUncle/ssgen/features/md_parse"
+	"github.com/TwilightUncle/ssgen/features/slug"
 )
 
 type MdHeaderInfo struct {
@@ -18,35 +21,28 @@ type MdHeaderInfo struct {
 
 type MdAllHeaaderInfo struct {
 	// 見出しのヘッダ部分の内容をキーにしたマップ
-	idMap map[string]MdHeaderInfo
+	// 複数ページで同じ見出しが使用されている場合、全てを解決の優先順に保持する
+	idMap map[string][]MdHeaderInfo
 
 	// 画面単位でグループ化した物
 	pageGroup map[string][]MdHeaderInfo
-}
 
-// マークダウン中の見出しパターン
-const mD_H_MATCH_PATTERN = `(?m)^(#{1,6}) +(.+)$`
+	// ページ名をキーにした、全てのページのメタデータ
+	pages map[string]md_parse.MetaData
+}
 
 // マークダウン中のリンク独自記法パターン
 const mD_AUTO_LINK_MATCH_PATTERN = `\[\{(.*?)\}\]`
 
-// 後方参照できなかった...
-// https://github.com/google/re2/wiki/Syntax
-const hTML_H_MATCH_PATTERN = `(?s)<(?P<begintag>h\d)(?P<attrs> ?[^<>]*)>(?P<inner>(?:<a ?[^<>]*>)?(?P<text>[^<>]*)(?:</a>)?)</(?P<endtag>h\d)>`
-
-// const hTML_H_MATCH_PATTERN = `(?s)<(h\d)( ?[^<>]*)>(.*?)</\1>`
-
 // マークダウンの見出し情報を全て抽出
-func getMdHeaderInfos(mdStr string, pagename string) []MdHeaderInfo {
-	exp := regexp.MustCompile(mD_H_MATCH_PATTERN)
-
+func getMdHeaderInfos(mdStr string, pagename string, fn slug.Func) []MdHeaderInfo {
 	var infos []MdHeaderInfo
-	for _, match := range exp.FindAllStringSubmatch(mdStr, -1) {
+	for _, h := range parseMdHeadings(mdStr, fn) {
 		infos = append(infos, MdHeaderInfo{
-			text:     match[2],
+			text:     h.text,
 			pagename: strings.ReplaceAll(pagename, "\\", "/"),
-			id:       url.QueryEscape(match[2]),
-			depth:    len(match[1]),
+			id:       h.id,
+			depth:    h.depth,
 		})
 	}
 	return infos
@@ -54,26 +50,92 @@ func getMdHeaderInfos(mdStr string, pagename string) []MdHeaderInfo {
 
 // 受け取った前の見出しの情報を取得
 // 返却されるmapのkeyは見出しとして表示される内容
-// 複数ページで同じ見出しが使用されている場合、処理において後に出現したものが優先される
-// ※ソースの段階では順序不定?
+// 複数ページで同じ見出しが使用されている場合、ページ名の短いもの、辞書順で前のものが優先される
+// 見出しのidはGitHubと互換のslugとする
 func NewMdAllHeaaderInfo(mdPathes access_md.MdPaths) (MdAllHeaaderInfo, error) {
+	return NewMdAllHeaaderInfoWithSlug(mdPathes, slug.GitHub)
+}
+
+// NewMdAllHeaaderInfoと同様。見出しのidをfnにより生成する
+func NewMdAllHeaaderInfoWithSlug(mdPathes access_md.MdPaths, fn slug.Func) (MdAllHeaaderInfo, error) {
+	pages := map[string]md_parse.MetaData{}
 	groupedInfos, err := mdPathes.MapFileContent(func(path string, data []byte) interface{} {
-		return getMdHeaderInfos(string(data), mdPathes.GetPageName(path))
+		// メタデータ部分は見出しの対象外
+		metaData, mdBytes, _ := md_parse.ParseFileBytes(data)
+		metaData.PageName = strings.ReplaceAll(mdPathes.GetPageName(path), "\\", "/")
+		pages[metaData.PageName] = metaData
+		return getMdHeaderInfos(string(mdBytes), metaData.PageName, fn)
 	})
 
 	if err != nil {
 		return MdAllHeaaderInfo{}, err
 	}
 
-	idMap := map[string]MdHeaderInfo{}
+	idMap := map[string][]MdHeaderInfo{}
 	pageGroup := map[string][]MdHeaderInfo{}
 	for _, infos := range groupedInfos {
 		for _, hInfo := range infos.([]MdHeaderInfo) {
-			idMap[hInfo.text] = hInfo
+			idMap[hInfo.text] = append(idMap[hInfo.text], hInfo)
 			pageGroup[hInfo.pagename] = append(pageGroup[hInfo.pagename], hInfo)
 		}
 	}
-	return MdAllHeaaderInfo{idMap: idMap, pageGroup: pageGroup}, nil
+	for _, hInfos := range idMap {
+		sort.SliceStable(hInfos, func(i, j int) bool {
+			return lessPagename(hInfos[i].pagename, hInfos[j].pagename)
+		})
+	}
+	return MdAllHeaaderInfo{idMap: idMap, pageGroup: pageGroup, pages: pages}, nil
+}
+
+// 全てのページのメタデータを、ページ名の辞書順で返す
+func (a MdAllHeaaderInfo) Pages() []md_parse.MetaData {
+	names := make([]string, 0, len(a.pages))
+	for name := range a.pages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pages := make([]md_parse.MetaData, 0, len(names))
+	for _, name := range names {
+		pages = append(pages, a.pages[name])
+	}
+	return pages
+}
+
+// keepが真を返すページのみを含む見出し情報を返す。優先順は維持する
+// 下書き等を除外したサイトへ、ファイルを読み直さずに用いるため
+func (a MdAllHeaaderInfo) Filter(keep func(pagename string) bool) MdAllHeaaderInfo {
+	result := MdAllHeaaderInfo{
+		idMap:     map[string][]MdHeaderInfo{},
+		pageGroup: map[string][]MdHeaderInfo{},
+		pages:     map[string]md_parse.MetaData{},
+	}
+	for text, hInfos := range a.idMap {
+		for _, hInfo := range hInfos {
+			if keep(hInfo.pagename) {
+				result.idMap[text] = append(result.idMap[text], hInfo)
+			}
+		}
+	}
+	for pagename, hInfos := range a.pageGroup {
+		if keep(pagename) {
+			result.pageGroup[pagename] = hInfos
+		}
+	}
+	for pagename, metaData := range a.pages {
+		if keep(pagename) {
+			result.pages[pagename] = metaData
+		}
+	}
+	return result
+}
+
+// 候補が複数存在する場合の優先順。ページ名の短いもの、辞書順で前のものを優先する
+func lessPagename(a string, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
 }
 
 // 文字列部分、パス部分、ID部分に文字列を分割
@@ -119,107 +181,231 @@ func splitStrPathId(target string) (string, string, string) {
 	return str, path, id
 }
 
-// pathに合致するmdの場所を検索し、返却
-func searchFirstPath(path string, allHeaderInfos MdAllHeaaderInfo) string {
+// pathに合致するmdのページ名を、優先順に全て返却
+// 完全一致するものが存在する場合はそれのみを返す。'/'から始まる場合は完全一致のみ検索する
+func searchPaths(path string, allHeaderInfos MdAllHeaaderInfo) []string {
 	// まず、完全一致のチェック
-	for name := range allHeaderInfos.pageGroup {
-		if name == path {
-			return name
+	exact := strings.TrimPrefix(path, "/")
+	if _, ok := allHeaderInfos.pages[exact]; ok {
+		return []string{exact}
+	}
+	if strings.HasPrefix(path, "/") {
+		return nil
+	}
+
+	// 末尾一致のチェック。'/'区切りの単位で比較する
+	var names []string
+	for name := range allHeaderInfos.pages {
+		if strings.HasSuffix(name, "/"+path) {
+			names = append(names, name)
 		}
 	}
-	// 末尾一致のチェック
-	for name := range allHeaderInfos.pageGroup {
-		exp := regexp.MustCompile(path + `$`)
-		if exp.MatchString(name) {
-			return name
+	sort.Slice(names, func(i, j int) bool {
+		return lessPagename(names[i], names[j])
+	})
+	return names
+}
+
+// ページ中の、idもしくは見出しの内容が合致する見出しを返却
+func findIdInPagename(id string, pagename string, allHeaderInfos MdAllHeaaderInfo) (MdHeaderInfo, bool) {
+	for _, hInfo := range allHeaderInfos.pageGroup[pagename] {
+		if hInfo.id == id || hInfo.text == id {
+			return hInfo, true
 		}
 	}
-	return ""
+	return MdHeaderInfo{}, false
 }
 
-func existsIdInPagename(id string, pagename string, allHeaderInfos MdAllHeaaderInfo) bool {
-	group := allHeaderInfos.pageGroup[pagename]
-	exists := false
-	for _, hInfo := range group {
-		if hInfo.id == id {
-			exists = true
-			break
+// 参照の記述自体が不正であればエラーを返す
+func validateRef(match string) error {
+	str, path, _ := splitStrPathId(match)
+	if str == "" {
+		return errors.New("malformed reference")
+	}
+	if path == "" {
+		return nil
+	}
+	for _, segment := range strings.Split(strings.TrimPrefix(path, "/"), "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("invalid path segment %q", segment)
 		}
 	}
-	return exists
+	return nil
 }
 
-// 置換する文字列を生成
-func makeReplaceStr(match string, baseUrl string, allHeaderInfos MdAllHeaaderInfo, suffix string) (string, string, bool) {
+// 独自記法の参照を解決する
+// 候補が複数存在する場合、優先順の先頭を採用し、全ての候補を返却する
+func resolve(match string, baseUrl string, allHeaderInfos MdAllHeaaderInfo, suffix string) (str string, link string, candidates []string, ok bool) {
 	str, path, id := splitStrPathId(match)
 
 	if str == "" {
-		return match, "", false
+		return match, "", nil, false
 	}
 
 	if path == "" {
-		hInfo, ok := allHeaderInfos.idMap[id]
-		if !ok {
-			return str, "", false
+		hInfos := allHeaderInfos.idMap[id]
+		if len(hInfos) == 0 {
+			return str, "", nil, false
 		}
-		path = hInfo.pagename + suffix + "#" + hInfo.id
+		for _, hInfo := range hInfos {
+			candidates = append(candidates, hInfo.pagename+"#"+hInfo.id)
+		}
+		path = hInfos[0].pagename + suffix + "#" + hInfos[0].id
 	} else {
-		pagename := searchFirstPath(path, allHeaderInfos)
-		if pagename == "" {
-			return str, "", false
+		pagenames := searchPaths(path, allHeaderInfos)
+		if len(pagenames) == 0 {
+			return str, "", nil, false
 		}
-		path = pagename + suffix
+		candidates = pagenames
+		path = pagenames[0] + suffix
 
 		if id != "" {
-			if !existsIdInPagename(id, pagename, allHeaderInfos) {
-				return str, "", false
+			hInfo, exists := findIdInPagename(id, pagenames[0], allHeaderInfos)
+			if !exists {
+				return str, "", nil, false
 			}
-			path += "#" + id
+			path += "#" + hInfo.id
 		}
 	}
-	return str, baseUrl + "/" + path, true
+	return str, baseUrl + "/" + path, candidates, true
+}
+
+// 置換する文字列を生成
+func makeReplaceStr(match string, baseUrl string, allHeaderInfos MdAllHeaaderInfo, suffix string) (string, string, bool) {
+	str, link, _, ok := resolve(match, baseUrl, allHeaderInfos, suffix)
+	return str, link, ok
+}
+
+// 独自記法のリンクについての問題
+type Diagnostic struct {
+	// [{...}]の内側の記述
+	Ref string
+	// マークダウン中の行番号(1始まり)
+	Line    int
+	Message string
+	// 候補が複数存在した場合の全候補(優先順)。リンクが見つからなかった場合は空
+	Candidates []string
+	// 参照の記述自体が不正な場合はtrue
+	Invalid bool
 }
 
 // ページ名及び、独自記法から、マークダウンのリンクで置き換えたマークダウン文字列を返す
 // リンクが見つからなかったものは二つ目の引数において内容をカンマ区切り文字列で返却
 func MakeLink(baseUrl string, targetMdStr string, allHeaderInfos MdAllHeaaderInfo, suffix string) (string, string) {
+	replaced, diagnostics := MakeLinkWithDiagnostics(baseUrl, targetMdStr, allHeaderInfos, suffix)
+
+	notExistsLinks := []string{}
+	for _, d := range diagnostics {
+		if len(d.Candidates) == 0 && !d.Invalid {
+			notExistsLinks = append(notExistsLinks, d.Ref)
+		}
+	}
+	return replaced, strings.Join(notExistsLinks, ",")
+}
+
+// MakeLinkと同様に置き換えを行い、リンクが見つからなかったものを行番号付きで返却
+// コードブロック、インラインコード中の記述はそのままとする
+func MakeLinkWithDiagnostics(baseUrl string, targetMdStr string, allHeaderInfos MdAllHeaaderInfo, suffix string) (string, []Diagnostic) {
 	exp := regexp.MustCompile(mD_AUTO_LINK_MATCH_PATTERN)
+	codeRanges := mdCodeRanges(targetMdStr)
+	var locs [][]int
+	for _, loc := range exp.FindAllStringSubmatchIndex(targetMdStr, -1) {
+		if !inRanges(loc[0], codeRanges) {
+			locs = append(locs, loc)
+		}
+	}
 
 	// リンク部分の置き換え実施
-	replaced := exp.ReplaceAllStringFunc(targetMdStr, func(str string) string {
-		str, path, _ := makeReplaceStr(exp.FindStringSubmatch(str)[1], baseUrl, allHeaderInfos, suffix)
+	var b strings.Builder
+	prev := 0
+	for _, loc := range locs {
+		b.WriteString(targetMdStr[prev:loc[0]])
+		str, path, _ := makeReplaceStr(targetMdStr[loc[2]:loc[3]], baseUrl, allHeaderInfos, suffix)
 		if path == "" {
-			return str
+			b.WriteString(str)
+		} else {
+			fmt.Fprintf(&b, "[%s](%s)", str, path)
 		}
-		return fmt.Sprintf("[%s](%s)", str, path)
-	})
-
-	// リンク指定されているにも関わらず、該当のリンクが存在しない物を検出
-	notExistsLinks := []string{}
-	for _, match := range exp.FindAllStringSubmatch(targetMdStr, -1) {
-		if _, _, ok := makeReplaceStr(match[1], baseUrl, allHeaderInfos, suffix); !ok {
-			notExistsLinks = append(notExistsLinks, match[1])
+		prev = loc[1]
+	}
+	b.WriteString(targetMdStr[prev:])
+	replaced := b.String()
+
+	// リンク指定されているにも関わらず、該当のリンクが存在しない物、候補が複数存在する物を検出
+	diagnostics := []Diagnostic{}
+	for _, loc := range locs {
+		ref := targetMdStr[loc[2]:loc[3]]
+		line := strings.Count(targetMdStr[:loc[0]], "\n") + 1
+		if err := validateRef(ref); err != nil {
+			diagnostics = append(diagnostics, Diagnostic{
+				Ref:     ref,
+				Line:    line,
+				Message: fmt.Sprintf("invalid link [{%s}]: %v", ref, err),
+				Invalid: true,
+			})
+			continue
+		}
+		_, _, candidates, ok := resolve(ref, baseUrl, allHeaderInfos, suffix)
+		if !ok {
+			diagnostics = append(diagnostics, Diagnostic{
+				Ref:     ref,
+				Line:    line,
+				Message: fmt.Sprintf("unresolved link [{%s}]", ref),
+			})
+		}
+		if ok && len(candidates) > 1 {
+			diagnostics = append(diagnostics, Diagnostic{
+				Ref:  ref,
+				Line: line,
+				Message: fmt.Sprintf(
+					"ambiguous link [{%s}]: resolved to %s, candidates: %s",
+					ref,
+					candidates[0],
+					strings.Join(candidates, ", "),
+				),
+				Candidates: candidates,
+			})
 		}
 	}
-	return replaced, strings.Join(notExistsLinks, ",")
+	return replaced, diagnostics
+}
+
+// パンくずリストの1項目
+type BreadCrumb struct {
+	// ページのフロントマターのtitle。無い場合はディレクトリ名、ファイル名
+	Title string
+	// 階層に該当するページが存在しない場合は空
+	Url string
+	// 表示中のページであるか
+	IsCurrent bool
 }
 
 // 該当マークダウンの配置位置より、パンくずリストを作成
-// 階層に該当する画面が存在しない場合はリンクではないただの文字列として表示する
-func MakeBreadCrumbs(baseUrl string, pagename string, allHeaderInfos MdAllHeaaderInfo, suffix string) [][2]string {
+// ディレクトリはdir/index.md(無い場合はdir.md)をそのページとし、
+// 存在しない場合はリンクではないただの文字列として表示する
+func MakeBreadCrumbs(baseUrl string, pagename string, allHeaderInfos MdAllHeaaderInfo, suffix string) []BreadCrumb {
 	splited := strings.Split(pagename, "/")
-	checkPath := make([]string, 0)
-	result := make([][2]string, 0)
-
-	for _, name := range splited {
-		checkPath = append(checkPath, name)
-		str, path, _ := makeReplaceStr(
-			name+"|"+strings.Join(checkPath, "/"),
-			baseUrl,
-			allHeaderInfos,
-			suffix,
-		)
-		result = append(result, [2]string{str, path})
+	// dir/indexはdirのページとして扱う
+	if len(splited) > 1 && splited[len(splited)-1] == indexPageName {
+		splited = splited[:len(splited)-1]
+	}
+
+	result := make([]BreadCrumb, 0, len(splited))
+	for i, name := range splited {
+		crumbPage := strings.Join(splited[:i+1], "/")
+		isCurrent := i == len(splited)-1
+		if isCurrent {
+			crumbPage = pagename
+		} else if _, ok := allHeaderInfos.pages[crumbPage+"/"+indexPageName]; ok {
+			crumbPage += "/" + indexPageName
+		}
+
+		crumb := BreadCrumb{Title: name, IsCurrent: isCurrent}
+		if metaData, ok := allHeaderInfos.pages[crumbPage]; ok {
+			crumb.Title = pageTitle(metaData, crumbPage)
+			crumb.Url = baseUrl + "/" + crumbPage + suffix
+		}
+		result = append(result, crumb)
 	}
 	return result
 }
@@ -233,7 +419,7 @@ func MakePageInnerPaths(baseUrl string, pagename string, depth int, allHeaderInf
 	for _, info := range allHeaderInfos.pageGroup[pagename] {
 		if info.depth == depth {
 			str, path, _ := makeReplaceStr(
-				info.text+"|"+pagename+"#"+info.id,
+				info.text+"|/"+pagename+"#"+info.id,
 				baseUrl,
 				allHeaderInfos,
 				suffix,
@@ -243,19 +429,3 @@ func MakePageInnerPaths(baseUrl string, pagename string, depth int, allHeaderInf
 	}
 	return result
 }
-
-// HTMLの見出し要素にid属性を付与
-func AddIdForHtmlH(html string) string {
-	exp := regexp.MustCompile(hTML_H_MATCH_PATTERN)
-	return exp.ReplaceAllStringFunc(html, func(str string) string {
-		match := exp.FindStringSubmatch(str)
-		return fmt.Sprintf(
-			"<%s%s id=\"%s\">%s</%s>",
-			match[exp.SubexpIndex("begintag")],
-			match[exp.SubexpIndex("attrs")],
-			match[exp.SubexpIndex("text")],
-			match[exp.SubexpIndex("inner")],
-			match[exp.SubexpIndex("endtag")],
-		)
-	})
-}
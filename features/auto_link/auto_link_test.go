@@ -4,9 +4,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/TwilightUncle/ssgen/features/access_md"
+	"github.com/TwilightUncle/ssgen/features/slug"
 	"github.com/TwilightUncle/ssgen/helpers/testing_helper"
 )
 
@@ -57,8 +60,28 @@ func makeTestFileData(t *testing.T) access_md.MdPaths {
 	return paths
 }
 
+// filesのパス(区切り文字は'/')と内容でマークダウンを作成し、全ページの見出し情報を収集する
+func newTestHeaderInfos(t *testing.T, files map[string]string) MdAllHeaaderInfo {
+	baseDir := filepath.Join(os.TempDir(), "github.com/TwilightUncle/ssgen-auto_link_test-"+testing_helper.MakeRandomStr(32))
+	fileDatas := []testing_helper.TestFileData{}
+	for path, contents := range files {
+		fileDatas = append(fileDatas, testing_helper.TestFileData{Path: filepath.Join(baseDir, filepath.FromSlash(path)), Contents: []byte(contents)})
+	}
+	testing_helper.MakeTestFiles(baseDir, fileDatas, t)
+
+	mdPaths, err := access_md.NewMdPaths(baseDir, []string{}, []string{".md"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	allHInfos, err := NewMdAllHeaaderInfo(mdPaths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return allHInfos
+}
+
 func TestGetMdHeaderInfos(t *testing.T) {
-	infos := getMdHeaderInfos(page1, "page1")
+	infos := getMdHeaderInfos(page1, "page1", slug.GitHub)
 
 	// 抽出された数が正しいかテスト
 	wantLen := 6
@@ -93,28 +116,23 @@ func TestGetAllFileMdHeaderInfos(t *testing.T) {
 		t.Errorf("error by getAllFileMdHeaderInfos: %v", err)
 	}
 
-	wantInfos := map[string]MdHeaderInfo{
-		"ghi": {text: "ghi", pagename: "page1", id: "ghi", depth: 2},
-		"jkl": {text: "jkl", pagename: "page1", id: "jkl", depth: 3},
-		"mno": {text: "mno", pagename: "page1", id: "mno", depth: 4},
-		"pqr": {text: "pqr", pagename: "page1", id: "pqr", depth: 5},
-		"stu": {text: "stu", pagename: "page1", id: "stu", depth: 6},
-		"zyx": {text: "zyx", pagename: "sub/page2", id: "zyx", depth: 1},
-		"wvu": {text: "wvu", pagename: "sub/page2", id: "wvu", depth: 1},
-		// page2のdefで上書きされていること
-		"def": {text: "def", pagename: "sub/page2", id: "def", depth: 1},
+	wantInfos := map[string][]MdHeaderInfo{
+		"ghi": {{text: "ghi", pagename: "page1", id: "ghi", depth: 2}},
+		"jkl": {{text: "jkl", pagename: "page1", id: "jkl", depth: 3}},
+		"mno": {{text: "mno", pagename: "page1", id: "mno", depth: 4}},
+		"pqr": {{text: "pqr", pagename: "page1", id: "pqr", depth: 5}},
+		"stu": {{text: "stu", pagename: "page1", id: "stu", depth: 6}},
+		"zyx": {{text: "zyx", pagename: "sub/page2", id: "zyx", depth: 1}},
+		"wvu": {{text: "wvu", pagename: "sub/page2", id: "wvu", depth: 1}},
+		// 両方のdefが、ページ名の短い順に保持されていること
+		"def": {
+			{text: "def", pagename: "page1", id: "def", depth: 1},
+			{text: "def", pagename: "sub/page2", id: "def", depth: 1},
+		},
 	}
 	for key, wantInfo := range wantInfos {
-		if allHInfos.idMap[key] != wantInfo {
-			t.Errorf(
-				"Actual {text: %s, pagename: %s, id: %s}, want {text: %s, pagename: %s, id: %s}",
-				allHInfos.idMap[key].text,
-				allHInfos.idMap[key].pagename,
-				allHInfos.idMap[key].id,
-				wantInfo.text,
-				wantInfo.pagename,
-				wantInfo.id,
-			)
+		if !reflect.DeepEqual(allHInfos.idMap[key], wantInfo) {
+			t.Errorf("Actual [%+v], want [%+v]", allHInfos.idMap[key], wantInfo)
 		}
 	}
 }
@@ -172,7 +190,7 @@ abc[{def#}]ghi[{jkl#}]
 `
 	want1 := fmt.Sprintf(
 		`
-abc[def](%s/sub/page2#def)ghi[jkl](%s/page1#jkl)
+abc[def](%s/page1#def)ghi[jkl](%s/page1#jkl)
 [zyx](%s/sub/page2#zyx)
 [def](%s/page1#def)
 [def2](%s/sub/page2#def)
@@ -204,7 +222,7 @@ abc[def](%s/sub/page2#def)ghi[jkl](%s/page1#jkl)
 	`
 	want2 := fmt.Sprintf(
 		`
-abc[def](%s/sub/page2#def)ghij[jkl](%s/page1#jkl)
+abc[def](%s/page1#def)ghij[jkl](%s/page1#jkl)
 [zyx](%s/sub/page2#zyx)
 	`,
 		baseUrl,
@@ -221,6 +239,79 @@ abc[def](%s/sub/page2#def)ghij[jkl](%s/page1#jkl)
 	}
 }
 
+func TestMakeLinkWithDiagnostics(t *testing.T) {
+	mdPaths := makeTestFileData(t)
+	const baseUrl = "http://hostname.test/root"
+
+	allHInfos, err := NewMdAllHeaaderInfo(mdPaths)
+	if err != nil {
+		t.Error(err)
+	}
+
+	const target = `[{def#}]
+[{abc#}]
+
+text [{jkl#}] [{nothing|sub/nothing}]
+`
+	_, diagnostics := MakeLinkWithDiagnostics(baseUrl, target, allHInfos, "")
+	want := []Diagnostic{
+		{
+			Ref:        "def#",
+			Line:       1,
+			Message:    "ambiguous link [{def#}]: resolved to page1#def, candidates: page1#def, sub/page2#def",
+			Candidates: []string{"page1#def", "sub/page2#def"},
+		},
+		{Ref: "abc#", Line: 2, Message: "unresolved link [{abc#}]"},
+		{Ref: "nothing|sub/nothing", Line: 4, Message: "unresolved link [{nothing|sub/nothing}]"},
+	}
+	if !reflect.DeepEqual(diagnostics, want) {
+		t.Errorf("Actual [%+v], want [%+v]", diagnostics, want)
+	}
+}
+
+func TestResolvePriority(t *testing.T) {
+	allHInfos := newTestHeaderInfos(t, map[string]string{
+		"b/page.md":      "# Install\n",
+		"a/page.md":      "# Install\n",
+		"long/a/page.md": "# 使い方\n",
+		"page.md":        "# top\n",
+	})
+
+	cases := []struct {
+		ref        string
+		link       string
+		candidates []string
+	}{
+		// 完全一致が優先
+		{ref: "page", link: "/page", candidates: []string{"page"}},
+		// 末尾一致は短いもの、同じ長さであれば辞書順
+		{ref: "x|a/page", link: "/a/page", candidates: []string{"a/page"}},
+		{ref: "x|page", link: "/page", candidates: []string{"page"}},
+		{ref: "x|a/page#top", link: ""},
+		// '/'区切りの単位でのみ末尾一致する
+		{ref: "x|ge", link: ""},
+		{ref: "x|ong/a/page", link: ""},
+		// 正規表現として解釈しない
+		{ref: "c++", link: ""},
+		{ref: "a(b", link: ""},
+		{ref: "x|.*page", link: ""},
+		{ref: "x|page#Install", link: ""},
+		{ref: "Install#", link: "/a/page#install", candidates: []string{"a/page#install", "b/page#install"}},
+		// '/'から始まるパスは完全一致のみ
+		{ref: "x|/long/a/page", link: "/long/a/page", candidates: []string{"long/a/page"}},
+		{ref: "x|/a", link: ""},
+		// 見出しの内容によるページ内の見出しの指定
+		{ref: "x|/b/page#Install", link: "/b/page#install", candidates: []string{"b/page"}},
+		{ref: "x|long/a/page#使い方", link: "/long/a/page#使い方", candidates: []string{"long/a/page"}},
+	}
+	for _, c := range cases {
+		_, link, candidates, ok := resolve(c.ref, "", allHInfos, "")
+		if link != c.link || ok != (c.link != "") || !reflect.DeepEqual(candidates, c.candidates) {
+			t.Errorf("%s: Actual [%s %v %t], want [%s %v]", c.ref, link, candidates, ok, c.link, c.candidates)
+		}
+	}
+}
+
 func TestMakeBreadCrumbs(t *testing.T) {
 	mdPaths := makeTestFileData(t)
 	const baseUrl = "http://hostname.test/root"
@@ -231,14 +322,41 @@ func TestMakeBreadCrumbs(t *testing.T) {
 	}
 
 	breadCrumbs := MakeBreadCrumbs(baseUrl, "sub/page2", allHInfos, "")
-
-	if breadCrumbs[0] != [2]string{"sub", ""} {
-		t.Errorf("Actual [%+v], want [%+v]", breadCrumbs[0], [2]string{"sub", ""})
+	want := []BreadCrumb{
+		{Title: "sub", Url: ""},
+		{Title: "page2", Url: baseUrl + "/sub/page2", IsCurrent: true},
+	}
+	if !reflect.DeepEqual(breadCrumbs, want) {
+		t.Errorf("Actual [%+v], want [%+v]", breadCrumbs, want)
 	}
+}
 
-	want := [2]string{"page2", baseUrl + "/sub/page2"}
-	if breadCrumbs[1] != want {
-		t.Errorf("Actual [%+v], want [%+v]", breadCrumbs[1], want)
+func TestMakeBreadCrumbsWithTitle(t *testing.T) {
+	allHInfos := makeNavTestInfos(t)
+
+	cases := []struct {
+		pagename string
+		want     []BreadCrumb
+	}{
+		{pagename: "index", want: []BreadCrumb{{Title: "Home", Url: "/index.html", IsCurrent: true}}},
+		{pagename: "guide/index", want: []BreadCrumb{{Title: "Guide", Url: "/guide/index.html", IsCurrent: true}}},
+		{pagename: "guide/advanced/tips", want: []BreadCrumb{
+			{Title: "Guide", Url: "/guide/index.html"},
+			// 代表のページが無いディレクトリはディレクトリ名
+			{Title: "advanced"},
+			{Title: "Tips", Url: "/guide/advanced/tips.html", IsCurrent: true},
+		}},
+		// タイトルの無いページはファイル名
+		{pagename: "secret/page", want: []BreadCrumb{
+			{Title: "secret", Url: "/secret/index.html"},
+			{Title: "page", Url: "/secret/page.html", IsCurrent: true},
+		}},
+	}
+	for _, c := range cases {
+		actual := MakeBreadCrumbs("", c.pagename, allHInfos, ".html")
+		if !reflect.DeepEqual(actual, c.want) {
+			t.Errorf("%s: Actual [%+v], want [%+v]", c.pagename, actual, c.want)
+		}
 	}
 }
 
@@ -258,3 +376,54 @@ func TestMakePageInnerPaths(t *testing.T) {
 		t.Errorf("Actual [%+v], want [%+v]", paths[0], want)
 	}
 }
+
+func TestMakeLinkInvalid(t *testing.T) {
+	allHInfos, err := NewMdAllHeaaderInfo(makeTestFileData(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const target = `[{#nomatch}]
+[{x|sub//page2}] [{x|../page1}]
+[{a(b}]
+`
+	_, diagnostics := MakeLinkWithDiagnostics("", target, allHInfos, "")
+	want := []Diagnostic{
+		{Ref: "#nomatch", Line: 1, Message: "invalid link [{#nomatch}]: malformed reference", Invalid: true},
+		{Ref: "x|sub//page2", Line: 2, Message: `invalid link [{x|sub//page2}]: invalid path segment ""`, Invalid: true},
+		{Ref: "x|../page1", Line: 2, Message: `invalid link [{x|../page1}]: invalid path segment ".."`, Invalid: true},
+		{Ref: "a(b", Line: 3, Message: "unresolved link [{a(b}]"},
+	}
+	if !reflect.DeepEqual(diagnostics, want) {
+		t.Errorf("Actual [%+v], want [%+v]", diagnostics, want)
+	}
+}
+
+func TestMakeLinkSkipsCode(t *testing.T) {
+	allHInfos, err := NewMdAllHeaaderInfo(makeTestFileData(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const target = "```json\n" +
+		`[{"url": "https://example.com/#top", "tags": "a|b"}]` + "\n" +
+		"```\n" +
+		"~~~~\n" +
+		"[{#nomatch}]\n" +
+		"~~~~\n" +
+		"inline `[{#nomatch}]` and ``[{x|`page1`}]`` but [{page2|sub/page2}]\n"
+	replaced, diagnostics := MakeLinkWithDiagnostics("", target, allHInfos, "")
+	want := strings.Replace(target, "[{page2|sub/page2}]", "[page2](/sub/page2)", 1)
+	if replaced != want {
+		t.Errorf("Actual [%s], want [%s]", replaced, want)
+	}
+	if len(diagnostics) != 0 {
+		t.Errorf("Actual [%+v], want no diagnostics", diagnostics)
+	}
+
+	// 閉じられていないバッククォートはコードとしない
+	_, diagnostics = MakeLinkWithDiagnostics("", "a ` [{#nomatch}]\n", allHInfos, "")
+	if len(diagnostics) != 1 || !diagnostics[0].Invalid {
+		t.Errorf("Actual [%+v], want invalid link", diagnostics)
+	}
+}
@@ -0,0 +1,256 @@
+package auto_link
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/TwilightUncle/ssgen/features/slug"
+)
+
+// マークダウン中の見出しパターン
+var mdHeadingExp = regexp.MustCompile(`^(#{1,6}) +(.+)$`)
+
+// コードブロックの開始、終了行のパターン
+var mdFenceExp = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+
+// 見出しから取り除くインラインの記法
+var (
+	mdImageOrLinkExp = regexp.MustCompile(`!?\[([^\[\]]*)\]\([^()]*\)`)
+	mdAutoLinkExp    = regexp.MustCompile(mD_AUTO_LINK_MATCH_PATTERN)
+	mdEmphasisExp    = regexp.MustCompile("\\*+|~~|`+")
+	mdUnderscoreExp  = regexp.MustCompile(`(^|[^\p{L}\p{N}])_+|_+([^\p{L}\p{N}]|$)`)
+	htmlTagExp       = regexp.MustCompile(`<[^<>]*>`)
+)
+
+// HTML中の見出し要素のパターン
+var htmlHeadingExp = regexp.MustCompile(`(?s)<(?P<tag>h[1-6])(?P<attrs>\s[^<>]*)?>(?P<inner>.*?)</h[1-6]>`)
+
+// HTML中のid属性のパターン
+var htmlIdAttrExp = regexp.MustCompile(`(?i)\sid\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'=<>]+))`)
+
+// マークダウン中の見出し1つ分
+type mdHeading struct {
+	// 見出しの行(0始まり)
+	lineIndex int
+	depth     int
+	// {#id}を除いた見出しの内容
+	text string
+	id   string
+	// 見出しの行のうち、{#id}より前の部分
+	head string
+}
+
+// マークダウンから見出しを抽出し、ページ内で一意なidを割り当てる
+// {#id}の記述があればそれをidとし、無ければ見出しの内容からfnにより生成する
+// コードブロック内の行は見出しとして扱わない
+func parseMdHeadings(mdStr string, fn slug.Func) []mdHeading {
+	slugger := slug.New(fn)
+	var headings []mdHeading
+	for i, mdLine := range scanMdLines(mdStr) {
+		if mdLine.inCode {
+			continue
+		}
+		line := strings.TrimSuffix(mdLine.text, "\r")
+
+		match := mdHeadingExp.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		head, customId := splitCustomId(line)
+		text := trimClosingHashes(strings.TrimSpace(head[len(match[1]):]))
+
+		var id string
+		if customId != "" {
+			id = slugger.Unique(customId)
+		} else {
+			id = slugger.Slug(plainText(text))
+		}
+		headings = append(headings, mdHeading{
+			lineIndex: i,
+			depth:     len(match[1]),
+			text:      text,
+			id:        id,
+			head:      head,
+		})
+	}
+	return headings
+}
+
+// マークダウンの1行
+type mdLine struct {
+	text string
+	// マークダウン中の行頭の位置
+	offset int
+	// コードブロック(区切りの行を含む)の内側であるか
+	inCode bool
+}
+
+// マークダウンを行に分割し、各行がコードブロックの内側であるかを判定する
+func scanMdLines(mdStr string) []mdLine {
+	var lines []mdLine
+	fence := ""
+	offset := 0
+	for _, text := range strings.Split(mdStr, "\n") {
+		line := mdLine{text: text, offset: offset, inCode: fence != ""}
+		offset += len(text) + 1
+		if match := mdFenceExp.FindStringSubmatch(strings.TrimSuffix(text, "\r")); match != nil {
+			if fence == "" {
+				fence = match[1]
+			} else if match[1][0] == fence[0] && len(match[1]) >= len(fence) {
+				fence = ""
+			}
+			line.inCode = true
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// コードブロック、インラインコードの範囲([開始, 終了))を返す
+func mdCodeRanges(mdStr string) [][2]int {
+	var ranges [][2]int
+	for _, line := range scanMdLines(mdStr) {
+		if line.inCode {
+			ranges = append(ranges, [2]int{line.offset, line.offset + len(line.text)})
+			continue
+		}
+		for _, span := range inlineCodeSpans(line.text) {
+			ranges = append(ranges, [2]int{line.offset + span[0], line.offset + span[1]})
+		}
+	}
+	return ranges
+}
+
+// 行中のインラインコード(同じ長さのバッククォートで囲まれた範囲)を返す
+func inlineCodeSpans(line string) [][2]int {
+	var spans [][2]int
+	for i := 0; i < len(line); {
+		if line[i] != '`' {
+			i++
+			continue
+		}
+		n := backtickRun(line, i)
+		end := -1
+		for j := i + n; j < len(line); {
+			if line[j] != '`' {
+				j++
+				continue
+			}
+			m := backtickRun(line, j)
+			if m == n {
+				end = j + m
+				break
+			}
+			j += m
+		}
+		if end < 0 {
+			i += n
+			continue
+		}
+		spans = append(spans, [2]int{i, end})
+		i = end
+	}
+	return spans
+}
+
+// iから連続するバッククォートの数
+func backtickRun(line string, i int) int {
+	n := 0
+	for i+n < len(line) && line[i+n] == '`' {
+		n++
+	}
+	return n
+}
+
+// 位置がいずれかの範囲に含まれるか
+func inRanges(pos int, ranges [][2]int) bool {
+	for _, r := range ranges {
+		if r[0] <= pos && pos < r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// 見出しの行を{#id}より前の部分とidに分割する
+func splitCustomId(line string) (string, string) {
+	begin := strings.Index(line, "{#")
+	if begin < 0 {
+		return line, ""
+	}
+	end := strings.Index(line[begin:], "}")
+	if end < 0 {
+		return line, ""
+	}
+	return strings.TrimRight(line[:begin], " "), strings.TrimSpace(line[begin+2 : begin+end])
+}
+
+// 見出し末尾の閉じの#を取り除く
+func trimClosingHashes(text string) string {
+	trimmed := strings.TrimRight(text, "#")
+	if trimmed == text || trimmed == "" || !strings.HasSuffix(trimmed, " ") {
+		return text
+	}
+	return strings.TrimRight(trimmed, " ")
+}
+
+// 見出しのマークダウンから、表示される文字列を取得する
+func plainText(text string) string {
+	text = mdAutoLinkExp.ReplaceAllStringFunc(text, func(match string) string {
+		str, _, _ := splitStrPathId(mdAutoLinkExp.FindStringSubmatch(match)[1])
+		return str
+	})
+	text = mdImageOrLinkExp.ReplaceAllString(text, "$1")
+	text = htmlTagExp.ReplaceAllString(text, "")
+	text = mdEmphasisExp.ReplaceAllString(text, "")
+	text = mdUnderscoreExp.ReplaceAllString(text, "$1$2")
+	return html.UnescapeString(text)
+}
+
+// マークダウンの見出しに{#id}の形式でidを付与する
+// 見出しのidはNewMdAllHeaaderInfoWithSlugによるものと一致する
+func AddIdForMdH(mdStr string, fn slug.Func) string {
+	headings := parseMdHeadings(mdStr, fn)
+	if len(headings) == 0 {
+		return mdStr
+	}
+
+	lines := strings.Split(mdStr, "\n")
+	for _, h := range headings {
+		cr := ""
+		if strings.HasSuffix(lines[h.lineIndex], "\r") {
+			cr = "\r"
+		}
+		lines[h.lineIndex] = fmt.Sprintf("%s {#%s}%s", h.head, h.id, cr)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// HTMLの見出し要素にid属性を付与
+// 既にid属性を持つ見出しはそのままとし、GitHubと互換のslugをidとする
+func AddIdForHtmlH(htmlStr string) string {
+	return AddIdForHtmlHWithSlug(htmlStr, slug.GitHub)
+}
+
+// AddIdForHtmlHと同様。idをfnにより生成する
+func AddIdForHtmlHWithSlug(htmlStr string, fn slug.Func) string {
+	// 既存のidと重複しないよう、あらかじめ登録しておく
+	slugger := slug.New(fn)
+	for _, match := range htmlIdAttrExp.FindAllStringSubmatch(htmlStr, -1) {
+		slugger.Unique(match[1] + match[2] + match[3])
+	}
+
+	return htmlHeadingExp.ReplaceAllStringFunc(htmlStr, func(str string) string {
+		match := htmlHeadingExp.FindStringSubmatch(str)
+		attrs := match[htmlHeadingExp.SubexpIndex("attrs")]
+		if htmlIdAttrExp.MatchString(attrs) {
+			return str
+		}
+		inner := match[htmlHeadingExp.SubexpIndex("inner")]
+		id := slugger.Slug(html.UnescapeString(htmlTagExp.ReplaceAllString(inner, "")))
+		tag := match[htmlHeadingExp.SubexpIndex("tag")]
+		return fmt.Sprintf("<%s%s id=\"%s\">%s</%s>", tag, attrs, html.EscapeString(id), inner, tag)
+	})
+}
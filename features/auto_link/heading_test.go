@@ -0,0 +1,67 @@
+package auto_link
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/TwilightUncle/ssgen/features/slug"
+)
+
+const headingPage = "# Hello World\n" +
+	"## Hello World\n" +
+	"## **Bold** and `code`\n" +
+	"## インストール 方法\n" +
+	"## Custom {#my-id}\n" +
+	"## Closed ##\n" +
+	"## See [{Other|other}] and [link](http://example.com)\n" +
+	"```sh\n" +
+	"# not a heading\n" +
+	"```\n" +
+	"## my-id\n"
+
+func TestParseMdHeadings(t *testing.T) {
+	headings := parseMdHeadings(headingPage, slug.GitHub)
+	want := []mdHeading{
+		{lineIndex: 0, depth: 1, text: "Hello World", id: "hello-world", head: "# Hello World"},
+		{lineIndex: 1, depth: 2, text: "Hello World", id: "hello-world-1", head: "## Hello World"},
+		{lineIndex: 2, depth: 2, text: "**Bold** and `code`", id: "bold-and-code", head: "## **Bold** and `code`"},
+		{lineIndex: 3, depth: 2, text: "インストール 方法", id: "インストール-方法", head: "## インストール 方法"},
+		{lineIndex: 4, depth: 2, text: "Custom", id: "my-id", head: "## Custom"},
+		{lineIndex: 5, depth: 2, text: "Closed", id: "closed", head: "## Closed ##"},
+		{lineIndex: 6, depth: 2, text: "See [{Other|other}] and [link](http://example.com)", id: "see-other-and-link", head: "## See [{Other|other}] and [link](http://example.com)"},
+		{lineIndex: 10, depth: 2, text: "my-id", id: "my-id-1", head: "## my-id"},
+	}
+	if !reflect.DeepEqual(headings, want) {
+		t.Errorf("Actual [%+v], want [%+v]", headings, want)
+	}
+}
+
+func TestAddIdForMdH(t *testing.T) {
+	actual := AddIdForMdH("# A\r\ntext\n# A {#b}\n```\n# c\n```\n", slug.GitHub)
+	want := "# A {#a}\r\ntext\n# A {#b}\n```\n# c\n```\n"
+	if actual != want {
+		t.Errorf("Actual [%q], want [%q]", actual, want)
+	}
+
+	// slug関数の差し替え
+	actual = AddIdForMdH("# A\n# A\n", strings.ToUpper)
+	want = "# A {#A}\n# A {#A-1}\n"
+	if actual != want {
+		t.Errorf("Actual [%q], want [%q]", actual, want)
+	}
+}
+
+func TestAddIdForHtmlH(t *testing.T) {
+	actual := AddIdForHtmlH(`<h1 id="intro">Intro</h1>
+<h2>Intro</h2>
+<h2 class="x"><a href="/">Tom &amp; Jerry</a></h2>
+<h3>日本語 見出し</h3>`)
+	want := `<h1 id="intro">Intro</h1>
+<h2 id="intro-1">Intro</h2>
+<h2 class="x" id="tom--jerry"><a href="/">Tom &amp; Jerry</a></h2>
+<h3 id="日本語-見出し">日本語 見出し</h3>`
+	if actual != want {
+		t.Errorf("Actual [%s], want [%s]", actual, want)
+	}
+}
@@ -0,0 +1,284 @@
+package auto_link
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/TwilightUncle/ssgen/features/md_parse"
+)
+
+// ディレクトリの代表となるページのファイル名(拡張子なし)
+const indexPageName = "index"
+
+// サイトのナビゲーションの1項目
+// ディレクトリはセクション、ページは葉となる
+type NavNode struct {
+	// フロントマターのtitle。無い場合はファイル名、ディレクトリ名
+	Title string
+	// ページのURL。代表のページ(index.md)の無いセクションは空
+	Url string
+	// ページ名。セクションの場合はディレクトリのパス
+	PageName  string
+	Weight    int
+	IsSection bool
+	// 表示中のページであるか
+	Active bool
+	// 表示中のページを含むセクションであるか
+	Expanded bool
+	Children []*NavNode
+}
+
+// サイト全体のナビゲーション。ページの一覧から一度だけ作成し、全てのページで使い回す
+type Nav struct {
+	// 表示中のページの無い状態の木
+	tree []*NavNode
+	// ナビゲーションの順序(セクションのページ、配下のページの順)に並べたページ名
+	order []string
+	// ページ名とorder中の位置
+	positions map[string]int
+
+	baseUrl        string
+	suffix         string
+	allHeaderInfos MdAllHeaaderInfo
+}
+
+// ページの一覧からナビゲーションを作成する
+// ディレクトリ中のindex.mdはディレクトリの代表のページとし、セクションのタイトル、URL、並び順に用いる
+// 直下のindex.mdはサイトの代表のページとし、weightによらず先頭に置く
+// 同じ階層の項目はweightの昇順、同じweightであれば名前の辞書順に並べる
+// hidden: trueのページは表示せず、代表のページがhiddenのセクションは配下ごと表示しない
+func NewNav(baseUrl string, allHeaderInfos MdAllHeaaderInfo, suffix string) *Nav {
+	root := &NavNode{IsSection: true}
+	sections := map[string]*NavNode{"": root}
+	pages := allHeaderInfos.Pages()
+
+	// 配下ごと表示しないディレクトリ
+	hiddenDirs := []string{}
+	for _, page := range pages {
+		if page.Hidden && path.Base(page.PageName) == indexPageName && path.Dir(page.PageName) != "." {
+			hiddenDirs = append(hiddenDirs, path.Dir(page.PageName)+"/")
+		}
+	}
+
+	for _, page := range pages {
+		if page.Hidden || hasAnyPrefix(page.PageName, hiddenDirs) {
+			continue
+		}
+
+		dir := path.Dir(page.PageName)
+		if dir == "." {
+			dir = ""
+		}
+		parent := navSection(sections, dir)
+		url := baseUrl + "/" + page.PageName + suffix
+
+		// ディレクトリの代表のページ
+		if dir != "" && path.Base(page.PageName) == indexPageName {
+			if page.Title != "" {
+				parent.Title = page.Title
+			}
+			parent.Url = url
+			parent.Weight = page.Weight
+			continue
+		}
+
+		title := page.Title
+		if title == "" {
+			title = path.Base(page.PageName)
+		}
+		parent.Children = append(parent.Children, &NavNode{
+			Title:    title,
+			Url:      url,
+			PageName: page.PageName,
+			Weight:   page.Weight,
+			Children: []*NavNode{},
+		})
+	}
+
+	sortNav(root.Children)
+	// サイトの代表のページを先頭へ
+	for i, node := range root.Children {
+		if !node.IsSection && node.PageName == indexPageName {
+			copy(root.Children[1:i+1], root.Children[:i])
+			root.Children[0] = node
+			break
+		}
+	}
+
+	order := navOrder(root.Children, nil)
+	positions := make(map[string]int, len(order))
+	for i, name := range order {
+		positions[name] = i
+	}
+	return &Nav{
+		tree:           root.Children,
+		order:          order,
+		positions:      positions,
+		baseUrl:        baseUrl,
+		suffix:         suffix,
+		allHeaderInfos: allHeaderInfos,
+	}
+}
+
+// pagenameを表示中としたナビゲーションを返す
+// 表示中のページとそれを含むセクションのみ複製して印を付け、他の項目は全てのページで共有する
+func (n *Nav) Tree(pagename string) []*NavNode {
+	return markActive(n.tree, pagename)
+}
+
+func markActive(nodes []*NavNode, pagename string) []*NavNode {
+	var result []*NavNode
+	for i, node := range nodes {
+		active := node.PageName == pagename
+		if node.IsSection {
+			active = node.Url != "" && node.PageName+"/"+indexPageName == pagename
+		}
+		expanded := node.IsSection && strings.HasPrefix(pagename, node.PageName+"/")
+		if !active && !expanded {
+			continue
+		}
+
+		if result == nil {
+			result = append([]*NavNode(nil), nodes...)
+		}
+		marked := *node
+		marked.Active = active
+		marked.Expanded = expanded
+		if expanded {
+			marked.Children = markActive(node.Children, pagename)
+		}
+		result[i] = &marked
+	}
+	if result == nil {
+		return nodes
+	}
+	return result
+}
+
+// ページの一覧から、pagenameを表示中としたナビゲーションを作成する
+// 複数のページに用いる場合は、NewNavで作成したものを使い回すこと
+func MakeNav(baseUrl string, pagename string, allHeaderInfos MdAllHeaaderInfo, suffix string) []*NavNode {
+	return NewNav(baseUrl, allHeaderInfos, suffix).Tree(pagename)
+}
+
+// ディレクトリに対応するセクションを返す。存在しない場合は親のセクションも含めて作成する
+func navSection(sections map[string]*NavNode, dir string) *NavNode {
+	if section, ok := sections[dir]; ok {
+		return section
+	}
+
+	parentDir := path.Dir(dir)
+	if parentDir == "." {
+		parentDir = ""
+	}
+	parent := navSection(sections, parentDir)
+	section := &NavNode{
+		Title:     path.Base(dir),
+		PageName:  dir,
+		IsSection: true,
+		Children:  []*NavNode{},
+	}
+	parent.Children = append(parent.Children, section)
+	sections[dir] = section
+	return section
+}
+
+func sortNav(nodes []*NavNode) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if nodes[i].Weight != nodes[j].Weight {
+			return nodes[i].Weight < nodes[j].Weight
+		}
+		return nodes[i].PageName < nodes[j].PageName
+	})
+	for _, node := range nodes {
+		sortNav(node.Children)
+	}
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// 前後のページへのリンク
+type PageLink struct {
+	Title string
+	Url   string
+}
+
+// ナビゲーションの順序(セクションのページ、配下のページの順)における、前後のページを返す
+// フロントマターのprev, nextが指定されている場合はそれを優先する。存在しない場合はnil
+func (n *Nav) PrevNext(pagename string) (*PageLink, *PageLink) {
+	var prev, next *PageLink
+	if i, ok := n.positions[pagename]; ok {
+		if i > 0 {
+			prev = makePageLink(n.baseUrl, n.order[i-1], n.allHeaderInfos, n.suffix)
+		}
+		if i < len(n.order)-1 {
+			next = makePageLink(n.baseUrl, n.order[i+1], n.allHeaderInfos, n.suffix)
+		}
+	}
+
+	metaData := n.allHeaderInfos.pages[pagename]
+	if metaData.Prev != "" {
+		prev = overridePageLink(n.baseUrl, metaData.Prev, n.allHeaderInfos, n.suffix)
+	}
+	if metaData.Next != "" {
+		next = overridePageLink(n.baseUrl, metaData.Next, n.allHeaderInfos, n.suffix)
+	}
+	return prev, next
+}
+
+// ページの一覧から、pagenameの前後のページを返す
+// 複数のページに用いる場合は、NewNavで作成したものを使い回すこと
+func MakePrevNext(baseUrl string, pagename string, allHeaderInfos MdAllHeaaderInfo, suffix string) (*PageLink, *PageLink) {
+	return NewNav(baseUrl, allHeaderInfos, suffix).PrevNext(pagename)
+}
+
+// ナビゲーションを深さ優先でたどり、ページ名を並べる
+func navOrder(nodes []*NavNode, order []string) []string {
+	for _, node := range nodes {
+		if node.IsSection && node.Url != "" {
+			order = append(order, node.PageName+"/"+indexPageName)
+		} else if !node.IsSection {
+			order = append(order, node.PageName)
+		}
+		order = navOrder(node.Children, order)
+	}
+	return order
+}
+
+// フロントマターで指定されたページへのリンク。見つからない場合はnil
+func overridePageLink(baseUrl string, ref string, allHeaderInfos MdAllHeaaderInfo, suffix string) *PageLink {
+	if validateRef(ref) != nil {
+		return nil
+	}
+	names := searchPaths(ref, allHeaderInfos)
+	if len(names) == 0 {
+		return nil
+	}
+	return makePageLink(baseUrl, names[0], allHeaderInfos, suffix)
+}
+
+func makePageLink(baseUrl string, pagename string, allHeaderInfos MdAllHeaaderInfo, suffix string) *PageLink {
+	return &PageLink{
+		Title: pageTitle(allHeaderInfos.pages[pagename], pagename),
+		Url:   baseUrl + "/" + pagename + suffix,
+	}
+}
+
+// ページのタイトル。フロントマターに無い場合はファイル名(index.mdの場合はディレクトリ名)
+func pageTitle(metaData md_parse.MetaData, pagename string) string {
+	if metaData.Title != "" {
+		return metaData.Title
+	}
+	if path.Base(pagename) == indexPageName && path.Dir(pagename) != "." {
+		return path.Base(path.Dir(pagename))
+	}
+	return path.Base(pagename)
+}
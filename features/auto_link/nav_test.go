@@ -0,0 +1,142 @@
+package auto_link
+
+import (
+	"reflect"
+	"testing"
+)
+
+func makeNavTestInfos(t *testing.T) MdAllHeaaderInfo {
+	return newTestHeaderInfos(t, map[string]string{
+		"index.md":               "---\ntitle: Home\nweight: 5\n---\n",
+		"about.md":               "no front matter",
+		"guide/index.md":         "---\ntitle: Guide\nweight: 1\n---\n",
+		"guide/install.md":       "---\ntitle: Install\nweight: 1\n---\n",
+		"guide/advanced/tips.md": "---\ntitle: Tips\n---\n",
+		"guide/draft.md":         "---\nhidden: true\n---\n",
+		"secret/index.md":        "---\nhidden: true\n---\n",
+		"secret/page.md":         "",
+	})
+}
+
+func TestMakeNav(t *testing.T) {
+	nav := MakeNav("/root", "guide/install", makeNavTestInfos(t), ".html")
+
+	// 直下のindex.mdはweightによらず先頭
+	want := []*NavNode{
+		{Title: "Home", Url: "/root/index.html", PageName: "index", Weight: 5, Children: []*NavNode{}},
+		{Title: "about", Url: "/root/about.html", PageName: "about", Children: []*NavNode{}},
+		{
+			Title: "Guide", Url: "/root/guide/index.html", PageName: "guide", Weight: 1, IsSection: true, Expanded: true,
+			Children: []*NavNode{
+				{
+					Title: "advanced", PageName: "guide/advanced", IsSection: true,
+					Children: []*NavNode{
+						{Title: "Tips", Url: "/root/guide/advanced/tips.html", PageName: "guide/advanced/tips", Children: []*NavNode{}},
+					},
+				},
+				{Title: "Install", Url: "/root/guide/install.html", PageName: "guide/install", Weight: 1, Active: true, Children: []*NavNode{}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(nav, want) {
+		t.Errorf("Actual [%s], want [%s]", formatNav(nav), formatNav(want))
+	}
+}
+
+func TestMakeNavSectionPage(t *testing.T) {
+	nav := MakeNav("", "guide/index", makeNavTestInfos(t), "")
+	guide := nav[2]
+	if !guide.Active || !guide.Expanded {
+		t.Errorf("want active and expanded section: %+v", guide)
+	}
+	if guide.Children[1].Active {
+		t.Errorf("want inactive page: %+v", guide.Children[1])
+	}
+}
+
+func TestNavTree(t *testing.T) {
+	nav := NewNav("", makeNavTestInfos(t), "")
+	install := nav.Tree("guide/install")
+	tips := nav.Tree("guide/advanced/tips")
+
+	// 表示中のページを含まない項目は共有し、元の木は変更しない
+	if install[1] != tips[1] || install[1] != nav.tree[1] {
+		t.Error("want shared node for about")
+	}
+	if install[2] == nav.tree[2] || nav.tree[2].Expanded || nav.tree[2].Children[1].Active {
+		t.Errorf("want copied section without changing the tree: %+v", nav.tree[2])
+	}
+	if install[2].Children[0] != nav.tree[2].Children[0] {
+		t.Error("want shared node for guide/advanced")
+	}
+	if !tips[2].Children[0].Expanded || !tips[2].Children[0].Children[0].Active || tips[2].Children[1].Active {
+		t.Errorf("Actual [%s]", formatNav(tips))
+	}
+}
+
+func formatNav(nodes []*NavNode) string {
+	result := "["
+	for _, node := range nodes {
+		result += "{" + node.Title + " " + node.Url + " " + formatNav(node.Children) + "}"
+	}
+	return result + "]"
+}
+
+func TestMakePrevNext(t *testing.T) {
+	allHInfos := makeNavTestInfos(t)
+
+	cases := []struct {
+		pagename string
+		prev     *PageLink
+		next     *PageLink
+	}{
+		{pagename: "index", next: &PageLink{Title: "about", Url: "/about"}},
+		{pagename: "about", prev: &PageLink{Title: "Home", Url: "/index"}, next: &PageLink{Title: "Guide", Url: "/guide/index"}},
+		// セクションのページの次は配下のページ
+		{pagename: "guide/index", prev: &PageLink{Title: "about", Url: "/about"}, next: &PageLink{Title: "Tips", Url: "/guide/advanced/tips"}},
+		{pagename: "guide/install", prev: &PageLink{Title: "Tips", Url: "/guide/advanced/tips"}},
+		// ナビゲーションに表示しないページ
+		{pagename: "guide/draft"},
+	}
+	for _, c := range cases {
+		prev, next := MakePrevNext("", c.pagename, allHInfos, "")
+		if !reflect.DeepEqual(prev, c.prev) || !reflect.DeepEqual(next, c.next) {
+			t.Errorf("%s: Actual [%+v %+v], want [%+v %+v]", c.pagename, prev, next, c.prev, c.next)
+		}
+	}
+}
+
+func TestMakePrevNextOverride(t *testing.T) {
+	allHInfos := newTestHeaderInfos(t, map[string]string{
+		"a.md":     "---\nnext: sub/c\nprev: nothing\n---\n",
+		"b.md":     "",
+		"sub/c.md": "---\ntitle: C\n---\n",
+	})
+
+	prev, next := MakePrevNext("", "a", allHInfos, ".html")
+	if prev != nil {
+		t.Errorf("Actual [%+v], want nil", prev)
+	}
+	want := &PageLink{Title: "C", Url: "/sub/c.html"}
+	if !reflect.DeepEqual(next, want) {
+		t.Errorf("Actual [%+v], want [%+v]", next, want)
+	}
+}
+
+func TestMdAllHeaaderInfoFilter(t *testing.T) {
+	allHInfos := makeNavTestInfos(t)
+	filtered := allHInfos.Filter(func(pagename string) bool {
+		return pagename != "guide/install"
+	})
+
+	if len(filtered.Pages()) != len(allHInfos.Pages())-1 {
+		t.Errorf("Actual [%d], want %d pages", len(filtered.Pages()), len(allHInfos.Pages())-1)
+	}
+	// 除外したページはナビゲーション、前後のページにも現れない
+	if _, next := NewNav("", filtered, "").PrevNext("guide/advanced/tips"); next != nil {
+		t.Errorf("Actual [%+v], want nil", next)
+	}
+	if _, next := NewNav("", allHInfos, "").PrevNext("guide/advanced/tips"); next == nil {
+		t.Error("want guide/install as next page of the unfiltered nav")
+	}
+}
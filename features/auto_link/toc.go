@@ -0,0 +1,93 @@
+package auto_link
+
+import (
+	"html"
+	"strings"
+)
+
+// マークダウン中に記述した位置へ目次を展開する記法(変換後のHTMLでは段落となる)
+const TOCMarker = "<p>[[toc]]</p>"
+
+// 目次の1項目
+type TOCNode struct {
+	// 見出しの表示内容(マークダウンの記法は取り除く)
+	Text     string
+	Id       string
+	Url      string
+	Depth    int
+	Children []*TOCNode
+}
+
+// ページの見出しから、入れ子の目次を作成する
+// minDepth～maxDepthの見出しのみを対象とし、0の場合はそれぞれ1, 6とする
+// 深さが飛んでいる場合は、直前のより浅い見出しの子とする
+func MakeTOC(baseUrl string, pagename string, minDepth int, maxDepth int, allHeaderInfos MdAllHeaaderInfo, suffix string) []*TOCNode {
+	if minDepth <= 0 {
+		minDepth = 1
+	}
+	if maxDepth <= 0 {
+		maxDepth = 6
+	}
+
+	roots := []*TOCNode{}
+	var stack []*TOCNode
+	for _, info := range allHeaderInfos.pageGroup[pagename] {
+		if info.depth < minDepth || info.depth > maxDepth {
+			continue
+		}
+		node := &TOCNode{
+			Text:     plainText(info.text),
+			Id:       info.id,
+			Url:      baseUrl + "/" + pagename + suffix + "#" + info.id,
+			Depth:    info.depth,
+			Children: []*TOCNode{},
+		}
+		for len(stack) > 0 && stack[len(stack)-1].Depth >= node.Depth {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			roots = append(roots, node)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, node)
+		}
+		stack = append(stack, node)
+	}
+	return roots
+}
+
+// 目次を入れ子のリストのHTMLにする。項目が無い場合は空文字
+func RenderTOC(nodes []*TOCNode) string {
+	if len(nodes) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(`<ul class="toc">`)
+	writeTOCItems(&b, nodes)
+	b.WriteString("</ul>")
+	return b.String()
+}
+
+func writeTOCItems(b *strings.Builder, nodes []*TOCNode) {
+	for _, node := range nodes {
+		b.WriteString(`<li><a href="`)
+		b.WriteString(html.EscapeString(node.Url))
+		b.WriteString(`">`)
+		b.WriteString(html.EscapeString(node.Text))
+		b.WriteString("</a>")
+		if len(node.Children) > 0 {
+			b.WriteString("<ul>")
+			writeTOCItems(b, node.Children)
+			b.WriteString("</ul>")
+		}
+		b.WriteString("</li>")
+	}
+}
+
+// HTML中の目次の記法を、ページの目次に置き換える
+func ExpandTOCMarker(htmlStr string, nodes []*TOCNode) string {
+	if !strings.Contains(htmlStr, TOCMarker) {
+		return htmlStr
+	}
+	return strings.ReplaceAll(htmlStr, TOCMarker, RenderTOC(nodes))
+}
@@ -0,0 +1,72 @@
+package auto_link
+
+import (
+	"reflect"
+	"testing"
+)
+
+const tocPage = `# Title
+## **First**
+### Sub 1
+#### Sub 1-1
+### Sub 2
+## Second
+#### Skipped
+`
+
+func makeTOCTestInfos(t *testing.T) MdAllHeaaderInfo {
+	return newTestHeaderInfos(t, map[string]string{"doc.md": tocPage})
+}
+
+func TestMakeTOC(t *testing.T) {
+	allHInfos := makeTOCTestInfos(t)
+
+	node := func(text string, id string, depth int, children ...*TOCNode) *TOCNode {
+		if children == nil {
+			children = []*TOCNode{}
+		}
+		return &TOCNode{Text: text, Id: id, Url: "/root/doc.html#" + id, Depth: depth, Children: children}
+	}
+
+	toc := MakeTOC("/root", "doc", 2, 0, allHInfos, ".html")
+	want := []*TOCNode{
+		node("First", "first", 2,
+			node("Sub 1", "sub-1", 3, node("Sub 1-1", "sub-1-1", 4)),
+			node("Sub 2", "sub-2", 3),
+		),
+		// 深さが飛んでいる見出しは直前の浅い見出しの子
+		node("Second", "second", 2, node("Skipped", "skipped", 4)),
+	}
+	if !reflect.DeepEqual(toc, want) {
+		t.Errorf("Actual [%+v], want [%+v]", toc, want)
+	}
+
+	toc = MakeTOC("/root", "doc", 1, 2, allHInfos, ".html")
+	want = []*TOCNode{
+		node("Title", "title", 1, node("First", "first", 2), node("Second", "second", 2)),
+	}
+	if !reflect.DeepEqual(toc, want) {
+		t.Errorf("Actual [%+v], want [%+v]", toc, want)
+	}
+
+	if toc := MakeTOC("/root", "nothing", 0, 0, allHInfos, ".html"); len(toc) != 0 {
+		t.Errorf("Actual [%+v], want empty", toc)
+	}
+}
+
+func TestRenderTOC(t *testing.T) {
+	toc := MakeTOC("", "doc", 3, 4, makeTOCTestInfos(t), "")
+	want := `<ul class="toc"><li><a href="/doc#sub-1">Sub 1</a><ul><li><a href="/doc#sub-1-1">Sub 1-1</a></li></ul></li><li><a href="/doc#sub-2">Sub 2</a><ul><li><a href="/doc#skipped">Skipped</a></li></ul></li></ul>`
+	if actual := RenderTOC(toc); actual != want {
+		t.Errorf("Actual [%s], want [%s]", actual, want)
+	}
+	if actual := RenderTOC(nil); actual != "" {
+		t.Errorf("Actual [%s], want empty", actual)
+	}
+
+	actual := ExpandTOCMarker("<p>a</p>\n<p>[[toc]]</p>\n<pre><code>[[toc]]</code></pre>", toc)
+	wantHtml := "<p>a</p>\n" + want + "\n<pre><code>[[toc]]</code></pre>"
+	if actual != wantHtml {
+		t.Errorf("Actual [%s], want [%s]", actual, wantHtml)
+	}
+}
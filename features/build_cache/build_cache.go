@@ -0,0 +1,104 @@
+package build_cache
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// キャッシュの形式や、キーの算出方法を変更した場合に更新する
+const version = 1
+
+// 一つの出力ファイルに対応するキャッシュ
+type Entry struct {
+	// 出力の元となった入力全体のハッシュ
+	Key string `json:"key"`
+	// 出力したファイルのパス
+	Output string `json:"output"`
+}
+
+// 前回のビルドにおける入力と出力の対応
+// Load関数、もしくはNew関数により生成すること
+type Cache struct {
+	Version   int    `json:"version"`
+	OutputDir string `json:"outputDir"`
+	// 変換元のファイルパスをキーにしたエントリ
+	Entries map[string]Entry `json:"entries"`
+}
+
+func New(outputDir string) *Cache {
+	return &Cache{Version: version, OutputDir: outputDir, Entries: map[string]Entry{}}
+}
+
+// キャッシュファイルを読み込む
+// 存在しない場合や、形式、出力先が異なる場合は空のキャッシュを返す
+func Load(path string, outputDir string) (*Cache, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return New(outputDir), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var c Cache
+	if err := json.Unmarshal(data, &c); err != nil || c.Version != version || c.OutputDir != outputDir {
+		return New(outputDir), nil
+	}
+	if c.Entries == nil {
+		c.Entries = map[string]Entry{}
+	}
+	return &c, nil
+}
+
+// キャッシュファイルを書き込む
+func (c *Cache) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path, data, 0666)
+}
+
+// 前回と同じキーで出力済みであり、出力ファイルも残っているか
+func (c *Cache) Fresh(source string, key string) bool {
+	entry, ok := c.Entries[source]
+	if !ok || entry.Key != key {
+		return false
+	}
+	_, err := os.Stat(entry.Output)
+	return err == nil
+}
+
+// 今回のビルドに含まれないエントリの出力ファイルを返す
+func (c *Cache) Stale(current *Cache) []string {
+	outputs := []string{}
+	for source, entry := range c.Entries {
+		if next, ok := current.Entries[source]; ok && next.Output == entry.Output {
+			continue
+		}
+		outputs = append(outputs, entry.Output)
+	}
+	return outputs
+}
+
+// 複数の入力を連結したハッシュを返す
+// 区切りの位置が異なる入力が同じハッシュとならないよう、各入力の長さも含める
+func Hash(parts ...[]byte) string {
+	h := sha256.New()
+	var size [8]byte
+	for _, part := range parts {
+		binary.BigEndian.PutUint64(size[:], uint64(len(part)))
+		h.Write(size[:])
+		h.Write(part)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,71 @@
+package build_cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TwilightUncle/ssgen/helpers/testing_helper"
+)
+
+func TestHash(t *testing.T) {
+	if Hash([]byte("ab"), []byte("c")) == Hash([]byte("a"), []byte("bc")) {
+		t.Errorf("want different hash for different boundaries")
+	}
+	if Hash([]byte("a"), []byte("b")) != Hash([]byte("a"), []byte("b")) {
+		t.Errorf("want same hash for same inputs")
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	baseDir := filepath.Join(os.TempDir(), "github.com/TwilightUncle/ssgen-build_cache_test-"+testing_helper.MakeRandomStr(32))
+	outputA := filepath.Join(baseDir, "out", "a.html")
+	outputB := filepath.Join(baseDir, "out", "b.html")
+	testing_helper.MakeTestFiles(baseDir, []testing_helper.TestFileData{
+		{Path: outputA, Contents: []byte("a")},
+	}, t)
+	cachePath := filepath.Join(baseDir, ".ssgen-cache")
+
+	// 存在しない場合は空
+	c, err := Load(cachePath, "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Entries) != 0 {
+		t.Errorf("Actual [%+v], want empty", c.Entries)
+	}
+
+	c.Entries["a.md"] = Entry{Key: "key-a", Output: outputA}
+	c.Entries["b.md"] = Entry{Key: "key-b", Output: outputB}
+	if err := c.Save(cachePath); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(cachePath, "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Fresh("a.md", "key-a") {
+		t.Errorf("want fresh: a.md")
+	}
+	if loaded.Fresh("a.md", "key-changed") {
+		t.Errorf("want not fresh when the key changed")
+	}
+	// 出力ファイルが存在しない場合
+	if loaded.Fresh("b.md", "key-b") {
+		t.Errorf("want not fresh when the output does not exist")
+	}
+
+	// 出力先が異なる場合は空
+	if other, _ := Load(cachePath, "other"); len(other.Entries) != 0 {
+		t.Errorf("Actual [%+v], want empty", other.Entries)
+	}
+
+	// 今回のビルドに含まれないもの
+	current := New("out")
+	current.Entries["a.md"] = Entry{Key: "key-a", Output: outputA}
+	stale := loaded.Stale(current)
+	if len(stale) != 1 || stale[0] != outputB {
+		t.Errorf("Actual [%v], want [%s]", stale, outputB)
+	}
+}
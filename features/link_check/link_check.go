@@ -0,0 +1,240 @@
+package link_check
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// 検出した問題の種類
+const (
+	KindDeadLink      = "dead link"
+	KindMissingAnchor = "missing anchor"
+	KindMissingAsset  = "missing asset"
+)
+
+// 出力されたHTML中の、解決できない参照
+type Problem struct {
+	// 出力ディレクトリからの相対パス(区切り文字は'/')
+	File string `json:"file"`
+	Line int    `json:"line"`
+	Kind string `json:"kind"`
+	// 参照元の属性名(href, src)
+	Attr string `json:"attr"`
+	Url  string `json:"url"`
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s:%d: %s: %s=%q", p.File, p.Line, p.Kind, p.Attr, p.Url)
+}
+
+// HTML中の参照
+type reference struct {
+	line  int
+	attr  string
+	url   string
+	asset bool
+}
+
+// 1ファイル分の解析結果
+type document struct {
+	ids  map[string]bool
+	refs []reference
+}
+
+// 参照を持たない、リンク先として扱わないスキーム
+var ignoredSchemes = map[string]bool{
+	"mailto":     true,
+	"tel":        true,
+	"javascript": true,
+	"data":       true,
+}
+
+// outputDir以下の全てのHTMLを解析し、リンク切れ、存在しないアンカー、存在しないアセッツを返す
+// baseUrlから始まるURLはoutputDirを起点として解決し、それ以外の外部URLは検査しない
+func Check(outputDir string, baseUrl string) ([]Problem, error) {
+	base, err := url.Parse(baseUrl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid baseUrl %q: %w", baseUrl, err)
+	}
+	basePath := strings.TrimSuffix(base.Path, "/")
+
+	docs := map[string]*document{}
+	err = filepath.WalkDir(outputDir, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !isHtml(filePath) {
+			return err
+		}
+		rel, err := filepath.Rel(outputDir, filePath)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		docs[filepath.ToSlash(rel)] = parse(data)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c := checker{outputDir: outputDir, base: base, basePath: basePath, docs: docs}
+	files := make([]string, 0, len(docs))
+	for file := range docs {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	problems := []Problem{}
+	for _, file := range files {
+		for _, ref := range docs[file].refs {
+			if kind, ok := c.check(file, ref); !ok {
+				problems = append(problems, Problem{File: file, Line: ref.line, Kind: kind, Attr: ref.attr, Url: ref.url})
+			}
+		}
+	}
+	return problems, nil
+}
+
+func isHtml(filePath string) bool {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	return ext == ".html" || ext == ".htm"
+}
+
+// HTMLからidと参照を行番号付きで取り出す
+func parse(data []byte) *document {
+	doc := &document{ids: map[string]bool{}}
+	tokenizer := html.NewTokenizer(bytes.NewReader(data))
+	line := 1
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			return doc
+		}
+		// トークン開始位置の行番号を記録してから、トークン中の改行を数える
+		tokenLine := line
+		line += bytes.Count(tokenizer.Raw(), []byte("\n"))
+		if tokenType != html.StartTagToken && tokenType != html.SelfClosingTagToken {
+			continue
+		}
+
+		token := tokenizer.Token()
+		for _, attr := range token.Attr {
+			switch {
+			case attr.Key == "id", attr.Key == "name" && token.Data == "a":
+				doc.ids[attr.Val] = true
+			case attr.Key == "href" && token.Data != "base":
+				// <link>の参照先はスタイルシート等のアセッツとして扱う
+				doc.refs = append(doc.refs, reference{line: tokenLine, attr: attr.Key, url: attr.Val, asset: token.Data == "link"})
+			case attr.Key == "src":
+				doc.refs = append(doc.refs, reference{line: tokenLine, attr: attr.Key, url: attr.Val, asset: true})
+			}
+		}
+	}
+}
+
+type checker struct {
+	outputDir string
+	base      *url.URL
+	basePath  string
+	docs      map[string]*document
+}
+
+// 参照を解決し、解決できない場合は問題の種類を返す
+func (c checker) check(file string, ref reference) (string, bool) {
+	kind := KindDeadLink
+	if ref.asset {
+		kind = KindMissingAsset
+	}
+
+	raw := strings.TrimSpace(ref.url)
+	if raw == "" {
+		return "", true
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return kind, false
+	}
+	if ignoredSchemes[strings.ToLower(u.Scheme)] {
+		return "", true
+	}
+
+	target, ok := c.resolvePath(file, u)
+	if !ok {
+		return "", true
+	}
+	targetFile, found := c.find(target)
+	if !found {
+		return kind, false
+	}
+
+	if u.Fragment == "" {
+		return "", true
+	}
+	doc, isDoc := c.docs[targetFile]
+	if !isDoc || doc.ids[u.Fragment] {
+		return "", true
+	}
+	return KindMissingAnchor, false
+}
+
+// 出力ディレクトリからの相対パスへ変換する。サイト外のURLの場合はfalse
+func (c checker) resolvePath(file string, u *url.URL) (string, bool) {
+	if u.Scheme != "" || u.Host != "" {
+		if c.base.Host == "" || !strings.EqualFold(u.Host, c.base.Host) {
+			return "", false
+		}
+	}
+
+	absolute := u.Scheme != "" || u.Host != ""
+	var urlPath string
+	switch {
+	case u.Path == "" && !absolute:
+		// 同一ページ内のアンカー
+		return file, true
+	case strings.HasPrefix(u.Path, "/") || absolute:
+		urlPath = "/" + strings.TrimPrefix(u.Path, "/")
+		if c.basePath != "" {
+			if urlPath != c.basePath && !strings.HasPrefix(urlPath, c.basePath+"/") {
+				return "", false
+			}
+			urlPath = strings.TrimPrefix(urlPath, c.basePath)
+		}
+	default:
+		urlPath = path.Join("/", path.Dir(file), u.Path)
+		if strings.HasSuffix(u.Path, "/") {
+			urlPath += "/"
+		}
+	}
+
+	// 末尾の'/'はディレクトリの参照として残す
+	cleaned := strings.TrimPrefix(path.Clean("/"+urlPath), "/")
+	if strings.HasSuffix(urlPath, "/") && cleaned != "" {
+		cleaned += "/"
+	}
+	return cleaned, true
+}
+
+// 参照先のファイルを探す。拡張子の省略とディレクトリのindex.htmlを許容する
+func (c checker) find(target string) (string, bool) {
+	candidates := []string{path.Join(target, "index.html")}
+	if target != "" && !strings.HasSuffix(target, "/") {
+		candidates = []string{target, target + ".html", path.Join(target, "index.html")}
+	}
+	for _, candidate := range candidates {
+		info, err := os.Stat(filepath.Join(c.outputDir, filepath.FromSlash(candidate)))
+		if err == nil && !info.IsDir() {
+			return candidate, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,90 @@
+package link_check
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/TwilightUncle/ssgen/helpers/testing_helper"
+)
+
+func makeTestOutput(t *testing.T) string {
+	baseDir := filepath.Join(os.TempDir(), "github.com/TwilightUncle/ssgen-link_check_test-"+testing_helper.MakeRandomStr(32))
+	testing_helper.MakeTestFiles(baseDir, []testing_helper.TestFileData{
+		{Path: filepath.Join(baseDir, "index.html"), Contents: []byte(`<html>
+<head><link rel="stylesheet" href="/docs/assets/style.css"></head>
+<body>
+<h1 id="top">Top</h1>
+<a href="#top">ok</a>
+<a href="#nothing">missing anchor</a>
+<a href="/docs/dir/page.html#section">ok</a>
+<a href="/docs/dir/page#section">ok</a>
+<a href="https://example.com/docs/dir/">ok</a>
+<a href="dir/page.html#missing">missing anchor</a>
+<a href="/docs/none.html">dead link</a>
+<img
+  src="assets/none.png">
+<a href="https://other.example.com/none.html">external</a>
+<a href="/other/none.html">outside of baseUrl</a>
+<a href="mailto:someone@example.com">mail</a>
+</body>
+</html>`)},
+		{Path: filepath.Join(baseDir, "dir", "index.html"), Contents: []byte(`<a href="../">ok</a>`)},
+		{Path: filepath.Join(baseDir, "dir", "page.html"), Contents: []byte(`<h2 id="section">Section</h2>
+<a href="../index.html#top">ok</a>
+<a name="named"></a><a href="#named">ok</a>
+<a href="../dir/nothing/">dead link</a>`)},
+		{Path: filepath.Join(baseDir, "assets", "style.css"), Contents: []byte("")},
+	}, t)
+	return baseDir
+}
+
+func TestCheck(t *testing.T) {
+	outputDir := makeTestOutput(t)
+
+	problems, err := Check(outputDir, "https://example.com/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Problem{
+		{File: "dir/page.html", Line: 4, Kind: KindDeadLink, Attr: "href", Url: "../dir/nothing/"},
+		{File: "index.html", Line: 6, Kind: KindMissingAnchor, Attr: "href", Url: "#nothing"},
+		{File: "index.html", Line: 10, Kind: KindMissingAnchor, Attr: "href", Url: "dir/page.html#missing"},
+		{File: "index.html", Line: 11, Kind: KindDeadLink, Attr: "href", Url: "/docs/none.html"},
+		{File: "index.html", Line: 12, Kind: KindMissingAsset, Attr: "src", Url: "assets/none.png"},
+	}
+	if !reflect.DeepEqual(problems, want) {
+		t.Errorf("Actual [%+v], want [%+v]", problems, want)
+	}
+}
+
+func TestCheckWithoutBaseUrl(t *testing.T) {
+	outputDir := makeTestOutput(t)
+
+	// baseUrlが空の場合、'/docs'から始まるURLはoutputDir直下の'docs'を参照する
+	problems, err := Check(outputDir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dead := map[string]bool{}
+	for _, p := range problems {
+		dead[p.Url] = true
+	}
+	for _, url := range []string{"/docs/assets/style.css", "/docs/dir/page.html#section", "/other/none.html"} {
+		if !dead[url] {
+			t.Errorf("want problem: %s", url)
+		}
+	}
+	if dead["https://example.com/docs/dir/"] {
+		t.Errorf("want external link ignored")
+	}
+}
+
+func TestProblemString(t *testing.T) {
+	p := Problem{File: "index.html", Line: 3, Kind: KindDeadLink, Attr: "href", Url: "/none.html"}
+	want := `index.html:3: dead link: href="/none.html"`
+	if p.String() != want {
+		t.Errorf("Actual [%s], want [%s]", p.String(), want)
+	}
+}
@@ -0,0 +1,225 @@
+package md_parse
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// ファイル先頭のBOM
+const utf8Bom = "\uFEFF"
+
+// フロントマターの形式
+type frontMatterFormat struct {
+	name string
+	// 開始、終了の区切りの行
+	open  string
+	close string
+	// 真の場合、区切りの行も内容に含める(JSONの{})
+	includeFences bool
+	// 読み取り。エラーの場合は内容中の行番号(1始まり、不明な場合は0)も返す
+	decode func(data []byte, v any) (int, error)
+	// 最上位の項目名と、内容中の行番号(1始まり)
+	keyLines func(data []byte) map[string]int
+}
+
+var frontMatterFormats = []*frontMatterFormat{
+	{name: "yaml", open: "---", close: "---", decode: decodeYaml, keyLines: yamlKeyLines},
+	{name: "toml", open: "+++", close: "+++", decode: decodeToml, keyLines: tomlKeyLines},
+	{name: "json", open: "{", close: "}", includeFences: true, decode: decodeJson, keyLines: jsonKeyLines},
+}
+
+// ファイルから切り出したフロントマター
+type frontMatter struct {
+	format *frontMatterFormat
+	data   []byte
+	// 内容の先頭が、ファイル中の何行目に当たるか(1始まり)
+	line int
+}
+
+// 内容中の行番号を、ファイル中の行番号を含むエラーにする
+func (fm *frontMatter) error(line int, err error) error {
+	err = fmt.Errorf("invalid %s front matter: %w", fm.format.name, err)
+	if line <= 0 {
+		return err
+	}
+	return &LineError{Line: fm.line + line - 1, Err: err}
+}
+
+// 最上位の項目名と、ファイル中の行番号(1始まり)。フロントマターが無い場合はnil
+func (fm *frontMatter) keyLines() map[string]int {
+	if fm == nil {
+		return nil
+	}
+	lines := fm.format.keyLines(fm.data)
+	for key, line := range lines {
+		lines[key] = fm.line + line - 1
+	}
+	return lines
+}
+
+// ファイルをフロントマターとマークダウン部分に分割し、マークダウン部分の先頭の行番号(1始まり)も返す
+// 区切りはそれぞれ単独の行である必要があり、終了の区切りが無い場合はフロントマター無しとして扱う
+// 先頭のBOMは取り除き、改行はLF、CRLFのいずれも扱う
+func splitFrontMatter(fileStr string) (*frontMatter, string, int) {
+	fileStr = strings.TrimPrefix(fileStr, utf8Bom)
+	// 改行を含めて分割する
+	lines := strings.SplitAfter(fileStr, "\n")
+	format := detectFormat(fenceLine(lines[0]))
+	if format == nil {
+		return nil, fileStr, 1
+	}
+
+	for i := 1; i < len(lines); i++ {
+		if fenceLine(lines[i]) != format.close {
+			continue
+		}
+		fm := &frontMatter{format: format, data: []byte(strings.Join(lines[1:i], "")), line: 2}
+		if format.includeFences {
+			fm.data = []byte(strings.Join(lines[:i+1], ""))
+			fm.line = 1
+		}
+		return fm, strings.Join(lines[i+1:], ""), i + 2
+	}
+	return nil, fileStr, 1
+}
+
+// 区切りの候補とする行。行末の改行、空白を取り除く
+func fenceLine(line string) string {
+	return strings.TrimRight(line, " \t\r\n")
+}
+
+// 開始の区切りの行からフロントマターの形式を判定する。該当しない場合はnil
+func detectFormat(line string) *frontMatterFormat {
+	for _, format := range frontMatterFormats {
+		if line == format.open {
+			return format
+		}
+	}
+	return nil
+}
+
+// yaml.v3のエラー中の行番号
+var yamlLinePattern = regexp.MustCompile(`line (\d+): (.*)$`)
+
+// yaml.v3のエラーメッセージ('line N: ...')を行番号とそれ以降の内容に分ける
+// 行番号を含まない場合はokが偽
+func SplitYamlErrLine(msg string) (line int, text string, ok bool) {
+	match := yamlLinePattern.FindStringSubmatch(msg)
+	if match == nil {
+		return 0, "", false
+	}
+	line, _ = strconv.Atoi(match[1])
+	return line, match[2], true
+}
+
+func decodeYaml(data []byte, v any) (int, error) {
+	err := yaml.Unmarshal(data, v)
+	if err == nil {
+		return 0, nil
+	}
+	msg := err.Error()
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) && len(typeErr.Errors) > 0 {
+		msg = typeErr.Errors[0]
+	}
+	if line, text, ok := SplitYamlErrLine(msg); ok {
+		return line, errors.New(text)
+	}
+	return 0, err
+}
+
+func decodeToml(data []byte, v any) (int, error) {
+	err := toml.Unmarshal(data, v)
+	if err == nil {
+		return 0, nil
+	}
+	var decodeErr *toml.DecodeError
+	if errors.As(err, &decodeErr) {
+		row, _ := decodeErr.Position()
+		return row, err
+	}
+	return 0, err
+}
+
+func decodeJson(data []byte, v any) (int, error) {
+	err := json.Unmarshal(data, v)
+	if err == nil {
+		return 0, nil
+	}
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return LineOf(data, syntaxErr.Offset), err
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return LineOf(data, typeErr.Offset), err
+	}
+	return 0, err
+}
+
+// バイト位置から1始まりの行番号を求める
+func LineOf(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+func yamlKeyLines(data []byte) map[string]int {
+	lines := map[string]int{}
+	var doc yaml.Node
+	if yaml.Unmarshal(data, &doc) != nil || len(doc.Content) == 0 {
+		return lines
+	}
+	mapping := doc.Content[0]
+	if mapping.Kind != yaml.MappingNode {
+		return lines
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		lines[mapping.Content[i].Value] = mapping.Content[i].Line
+	}
+	return lines
+}
+
+// tomlの最上位の項目(key = value)。テーブル([table])より後は対象外
+var tomlKeyPattern = regexp.MustCompile(`^\s*(?:"([^"]+)"|'([^']+)'|([A-Za-z0-9_-]+))\s*=`)
+
+func tomlKeyLines(data []byte) map[string]int {
+	lines := map[string]int{}
+	for i, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "[") {
+			break
+		}
+		if match := tomlKeyPattern.FindStringSubmatch(line); match != nil {
+			lines[match[1]+match[2]+match[3]] = i + 1
+		}
+	}
+	return lines
+}
+
+// jsonの項目("key": value)。入れ子の項目と同名の場合は先に現れた行とする
+var jsonKeyPattern = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"\s*:`)
+
+func jsonKeyLines(data []byte) map[string]int {
+	lines := map[string]int{}
+	for i, line := range strings.Split(string(data), "\n") {
+		for _, match := range jsonKeyPattern.FindAllStringSubmatch(line, -1) {
+			key, err := strconv.Unquote(`"` + match[1] + `"`)
+			if err != nil {
+				continue
+			}
+			if _, ok := lines[key]; !ok {
+				lines[key] = i + 1
+			}
+		}
+	}
+	return lines
+}
@@ -0,0 +1,73 @@
+package md_parse
+
+import (
+	"testing"
+)
+
+func TestSplitFrontMatter(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    string
+		format   string
+		data     string
+		body     string
+		bodyLine int
+	}{
+		{name: "yaml", input: "---\ntitle: a\n---\n# body\n", format: "yaml", data: "title: a\n", body: "# body\n", bodyLine: 4},
+		{name: "toml", input: "+++\ntitle = \"a\"\n+++\n# body", format: "toml", data: "title = \"a\"\n", body: "# body", bodyLine: 4},
+		{name: "json", input: "{\n  \"title\": \"a\"\n}\n# body", format: "json", data: "{\n  \"title\": \"a\"\n}\n", body: "# body", bodyLine: 4},
+		{name: "crlf", input: "---\r\ntitle: a\r\n---\r\n# body\r\n", format: "yaml", data: "title: a\r\n", body: "# body\r\n", bodyLine: 4},
+		{name: "bom", input: "\uFEFF---\ntitle: a\n---\n# body", format: "yaml", data: "title: a\n", body: "# body", bodyLine: 4},
+		{name: "trailing spaces", input: "--- \ntitle: a\n---\t\n# body", format: "yaml", data: "title: a\n", body: "# body", bodyLine: 4},
+		{name: "empty body", input: "---\ntitle: a\n---", format: "yaml", data: "title: a\n", body: "", bodyLine: 4},
+		// 値の中の---は区切りとしない
+		{name: "dashes in value", input: "---\ntitle: a---b\n---\n# body", format: "yaml", data: "title: a---b\n", body: "# body", bodyLine: 4},
+		// 区切りより後の水平線は本文に残す
+		{name: "later rule", input: "---\ntitle: a\n---\ntext\n\n---\nmore", format: "yaml", data: "title: a\n", body: "text\n\n---\nmore", bodyLine: 4},
+		// 単独の行でない区切りはフロントマターとしない
+		{name: "not own line", input: "---\ntitle: a\n---abc", body: "---\ntitle: a\n---abc", bodyLine: 1},
+		{name: "thematic break only", input: "---\n# body\n", body: "---\n# body\n", bodyLine: 1},
+		{name: "no front matter", input: "\uFEFF# body", body: "# body", bodyLine: 1},
+	}
+	for _, c := range cases {
+		fm, body, bodyLine := splitFrontMatter(c.input)
+		if c.format == "" {
+			if fm != nil {
+				t.Errorf("%s: Actual [%+v], want nil", c.name, fm)
+			}
+		} else if fm == nil || fm.format.name != c.format || string(fm.data) != c.data {
+			t.Errorf("%s: Actual [%+v], want format [%s] data [%q]", c.name, fm, c.format, c.data)
+		}
+		if body != c.body || bodyLine != c.bodyLine {
+			t.Errorf("%s: Actual [%q, %d], want [%q, %d]", c.name, body, bodyLine, c.body, c.bodyLine)
+		}
+	}
+}
+
+func TestSplitYamlErrLine(t *testing.T) {
+	cases := []struct {
+		msg  string
+		line int
+		text string
+		ok   bool
+	}{
+		{msg: "yaml: line 3: did not find expected node content", line: 3, text: "did not find expected node content", ok: true},
+		{msg: "line 12: cannot unmarshal !!str `a` into int", line: 12, text: "cannot unmarshal !!str `a` into int", ok: true},
+		{msg: "yaml: unmarshal errors", ok: false},
+	}
+	for _, c := range cases {
+		line, text, ok := SplitYamlErrLine(c.msg)
+		if line != c.line || text != c.text || ok != c.ok {
+			t.Errorf("%s: Actual [%d %q %t], want [%d %q %t]", c.msg, line, text, ok, c.line, c.text, c.ok)
+		}
+	}
+}
+
+func TestLineOf(t *testing.T) {
+	data := []byte("a\nb\r\nc")
+	for offset, want := range map[int64]int{0: 1, 1: 1, 2: 2, 5: 3, 100: 3} {
+		if actual := LineOf(data, offset); actual != want {
+			t.Errorf("%d: Actual [%d], want [%d]", offset, actual, want)
+		}
+	}
+}
@@ -1,44 +1,205 @@
 package md_parse
 
 import (
-	"regexp"
-
-	"gopkg.in/yaml.v3"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
 )
 
-const mETADATA_MATCH_PATTERN = `(?s)^---(.*?)---`
-
 type MetaData struct {
-	Title    string `yaml:"title"`
-	Overview string `yaml:"overview"`
-	PageName string
+	Title    string `yaml:"title" toml:"title" json:"title"`
+	Overview string `yaml:"overview" toml:"overview" json:"overview"`
+	// ナビゲーションでの並び順(昇順)
+	Weight int `yaml:"weight" toml:"weight" json:"weight"`
+	// 真の場合、ナビゲーションに表示しない
+	Hidden bool `yaml:"hidden" toml:"hidden" json:"hidden"`
+	// 前後のページの指定(サイト内リンクと同じ規則のパス)。空の場合はナビゲーションの順
+	Prev string `yaml:"prev" toml:"prev" json:"prev"`
+	Next string `yaml:"next" toml:"next" json:"next"`
+	// 真の場合、下書きとして公開しない
+	Draft bool `yaml:"draft" toml:"draft" json:"draft"`
+	// 公開の開始、終了日時(publishDate, expiryDate)。ゼロ値の場合は制限なし
+	// 形式により日時の表現が異なるため、フロントマターの値から個別に変換する
+	PublishDate time.Time `yaml:"-" toml:"-" json:"-"`
+	ExpiryDate  time.Time `yaml:"-" toml:"-" json:"-"`
+	// 上記以外のフロントマターの項目(author, tagsなど)。無い場合はnil
+	// テンプレートからは.page.Params.authorのように参照できる
+	Params   map[string]any `yaml:"-" toml:"-" json:"-"`
+	PageName string         `yaml:"-" toml:"-" json:"-"`
+	// マークダウン部分の先頭が、ファイル中の何行目に当たるか(1始まり)
+	BodyLine int `yaml:"-" toml:"-" json:"-"`
+	// ミドルウェア等が検出した、ビルドを止めるほどではない問題
+	Warnings []Warning `yaml:"-" toml:"-" json:"-"`
 }
 
-// ファイル内のうち、メタデータ部分を取得
-func getMetaData(fileStr string, metaDataMatcher *regexp.Regexp) (MetaData, error) {
-	// メタデータ読み取り
-	var metaData MetaData
-	if metaDataMatcher.MatchString(fileStr) {
-		metaStr := metaDataMatcher.FindStringSubmatch(fileStr)[1]
-		yamlParseErr := yaml.Unmarshal([]byte(metaStr), &metaData)
-		if yamlParseErr != nil {
-			return metaData, yamlParseErr
+// ページ中の問題
+type Warning struct {
+	// ファイル中の行番号(1始まり)。不明な場合は0
+	Line    int
+	Message string
+	// 問題を検出した処理(WarningSourceFrontMatter等)。空の場合はミドルウェア
+	Source string
+}
+
+// フロントマターのスキーマ検証による問題
+const WarningSourceFrontMatter = "front-matter"
+
+// ページ中の行番号付きのエラー
+type LineError struct {
+	// ファイル中の行番号(1始まり)
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// 行番号付きのエラーを生成する。lineはマークダウン部分の行番号
+func (m *MetaData) LineError(bodyLine int, err error) error {
+	return &LineError{Line: m.SourceLine(bodyLine), Err: err}
+}
+
+// マークダウン部分の行番号(1始まり)をファイル中の行番号に変換する
+func (m *MetaData) SourceLine(bodyLine int) int {
+	if m.BodyLine <= 0 {
+		return bodyLine
+	}
+	return m.BodyLine + bodyLine - 1
+}
+
+// 問題を追加する。lineはマークダウン部分の行番号
+func (m *MetaData) AddWarning(bodyLine int, message string) {
+	m.Warnings = append(m.Warnings, Warning{Line: m.SourceLine(bodyLine), Message: message})
+}
+
+// フロントマターの任意の項目の値を設定する
+func (m *MetaData) SetParam(key string, value any) {
+	if m.Params == nil {
+		m.Params = map[string]any{}
+	}
+	m.Params[key] = value
+}
+
+// MetaDataのフィールドとして読み取るフロントマターの項目名
+var knownKeys = func() map[string]bool {
+	keys := map[string]bool{}
+	t := reflect.TypeOf(MetaData{})
+	for i := 0; i < t.NumField(); i++ {
+		if name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ","); name != "" && name != "-" {
+			keys[name] = true
 		}
 	}
-	return metaData, nil
+	for key := range (&MetaData{}).dateFields() {
+		keys[key] = true
+	}
+	return keys
+}()
+
+// 日時の項目名と、対応するフィールド
+func (m *MetaData) dateFields() map[string]*time.Time {
+	return map[string]*time.Time{
+		"publishDate": &m.PublishDate,
+		"expiryDate":  &m.ExpiryDate,
+	}
 }
 
-// ファイル内のうち、マークダウン部分を取得
-func getMd(fileStr string, metaDataMatcher *regexp.Regexp) []byte {
-	mdStr := metaDataMatcher.ReplaceAllString(fileStr, "")
-	return []byte(mdStr)
+// 公開されないページの場合、その理由(draft等)を返す。公開される場合は空文字
+func (m MetaData) UnpublishedReason(now time.Time) string {
+	switch {
+	case m.Draft:
+		return "draft"
+	case !m.PublishDate.IsZero() && now.Before(m.PublishDate):
+		return "scheduled for " + m.PublishDate.Format(time.RFC3339)
+	case !m.ExpiryDate.IsZero() && !now.Before(m.ExpiryDate):
+		return "expired on " + m.ExpiryDate.Format(time.RFC3339)
+	}
+	return ""
+}
+
+// 公開されるページか。下書き、公開日時より前、有効期限以降のページは公開しない
+func (m MetaData) Published(now time.Time) bool {
+	return m.UnpublishedReason(now) == ""
+}
+
+// フロントマターのうち、MetaDataのフィールドに無い項目を取得する。無い場合はnil
+func extractParams(all map[string]any) map[string]any {
+	var params map[string]any
+	for key, value := range all {
+		if knownKeys[key] {
+			continue
+		}
+		if params == nil {
+			params = map[string]any{}
+		}
+		params[key] = value
+	}
+	return params
+}
+
+// フロントマターを読み取る。フロントマターが無い場合はゼロ値
+// 読み取りに失敗した場合は、形式とファイル中の行番号を含むエラーを返す
+func getMetaData(fm *frontMatter) (MetaData, error) {
+	metaData, _, err := decodeFrontMatter(fm)
+	return metaData, err
+}
+
+// フロントマターを読み取り、全ての項目の値も返す
+func decodeFrontMatter(fm *frontMatter) (MetaData, map[string]any, error) {
+	var metaData MetaData
+	if fm == nil {
+		return metaData, nil, nil
+	}
+
+	var all map[string]any
+	if line, err := fm.format.decode(fm.data, &all); err != nil {
+		return metaData, nil, fm.error(line, err)
+	}
+	if line, err := fm.format.decode(fm.data, &metaData); err != nil {
+		return metaData, nil, fm.error(line, err)
+	}
+	for key, dest := range metaData.dateFields() {
+		value, ok := all[key]
+		if !ok {
+			continue
+		}
+		date, err := parseDate(value, defaultDateFormats)
+		if err != nil {
+			return metaData, nil, fm.error(fm.format.keyLines(fm.data)[key], fmt.Errorf("%s: %w", key, err))
+		}
+		*dest = date
+	}
+	metaData.Params = extractParams(all)
+	return metaData, all, nil
 }
 
 // ファイル内容を読み取り、メタデータとマークダウンを取得する
+// フロントマターは先頭の区切りにより、YAML(---)、TOML(+++)、JSON({})のいずれかとして読み取る
 func ParseFileBytes(fileBytes []byte) (MetaData, []byte, error) {
-	fileStr := string(fileBytes)
-	exp := regexp.MustCompile(mETADATA_MATCH_PATTERN)
-	metaData, ok := getMetaData(fileStr, exp)
-	mdBytes := getMd(fileStr, exp)
-	return metaData, mdBytes, ok
+	return ParseFileBytesWithSchema(fileBytes, Schema{})
+}
+
+// ParseFileBytesと同様。加えてフロントマターをスキーマで検証する
+// 違反はWarningsへ追加し、schema.Strictの場合は全ての違反を含む*SchemaErrorを返す
+func ParseFileBytesWithSchema(fileBytes []byte, schema Schema) (MetaData, []byte, error) {
+	fm, mdStr, bodyLine := splitFrontMatter(string(fileBytes))
+	metaData, all, err := decodeFrontMatter(fm)
+	metaData.BodyLine = bodyLine
+	if err != nil {
+		return metaData, []byte(mdStr), err
+	}
+
+	violations := schema.validate(all, fm.keyLines())
+	if len(violations) > 0 && schema.Strict {
+		return metaData, []byte(mdStr), &SchemaError{Violations: violations}
+	}
+	for _, v := range violations {
+		metaData.Warnings = append(metaData.Warnings, Warning{Line: v.Line, Message: v.Error(), Source: WarningSourceFrontMatter})
+	}
+	return metaData, []byte(mdStr), nil
 }
@@ -1,32 +1,39 @@
 package md_parse
 
 import (
+	"errors"
 	"reflect"
-	"regexp"
+	"strings"
 	"testing"
+	"time"
 )
 
 const input1 = `---
 title: "test"
----abc testtest`
+---
+abc testtest`
 
 const input2 = `abc testtest`
 
+func frontMatterOf(fileStr string) *frontMatter {
+	fm, _, _ := splitFrontMatter(fileStr)
+	return fm
+}
+
 func TestGetMetaData(t *testing.T) {
-	exp := regexp.MustCompile(mETADATA_MATCH_PATTERN)
 
 	// メタデータ記述ありの場合
-	metaYaml, err := getMetaData(input1, exp)
+	metaYaml, err := getMetaData(frontMatterOf(input1))
 	want1 := MetaData{Title: "test"}
 	if err != nil {
 		t.Error(err)
 	}
-	if metaYaml != want1 {
+	if !reflect.DeepEqual(metaYaml, want1) {
 		t.Errorf("Actual [%+v], want [%+v]", metaYaml, want1)
 	}
 
 	// メタデータの記述なし
-	metaYaml, err = getMetaData(input2, exp)
+	metaYaml, err = getMetaData(frontMatterOf(input2))
 	if err != nil {
 		t.Error(err)
 	}
@@ -35,11 +42,162 @@ func TestGetMetaData(t *testing.T) {
 	}
 }
 
-func TestGetMd(t *testing.T) {
-	exp := regexp.MustCompile(mETADATA_MATCH_PATTERN)
-	md1 := string(getMd(input1, exp))
-	md2 := string(getMd(input2, exp))
-	if md1 != md2 {
-		t.Errorf("Want same md1 and md2")
+const input3 = `---
+title: "test"
+weight: 2
+author: "someone"
+tags: [go, ssg]
+draft_flag: true
+---
+abc testtest`
+
+func TestGetMetaDataParams(t *testing.T) {
+	metaData, err := getMetaData(frontMatterOf(input3))
+	if err != nil {
+		t.Error(err)
+	}
+	want := MetaData{
+		Title:  "test",
+		Weight: 2,
+		Params: map[string]any{
+			"author":     "someone",
+			"tags":       []any{"go", "ssg"},
+			"draft_flag": true,
+		},
+	}
+	if !reflect.DeepEqual(metaData, want) {
+		t.Errorf("Actual [%+v], want [%+v]", metaData, want)
+	}
+
+	metaData.SetParam("author", "other")
+	if metaData.Params["author"] != "other" {
+		t.Errorf("Actual [%v], want [other]", metaData.Params["author"])
+	}
+	var empty MetaData
+	empty.SetParam("key", 1)
+	if empty.Params["key"] != 1 {
+		t.Errorf("Actual [%v], want [1]", empty.Params["key"])
+	}
+}
+
+func TestParseFileBytesFormats(t *testing.T) {
+	want := MetaData{Title: "test", Weight: 2, Params: map[string]any{"author": "someone"}, BodyLine: 6}
+	inputs := map[string]string{
+		"yaml": "---\ntitle: test\nweight: 2\nauthor: someone\n---\n# body",
+		"toml": "+++\ntitle = \"test\"\nweight = 2\nauthor = \"someone\"\n+++\n# body",
+		"json": "{\n  \"title\": \"test\",\n  \"weight\": 2,\n  \"author\": \"someone\"\n}\n# body",
+	}
+	for name, input := range inputs {
+		metaData, md, err := ParseFileBytes([]byte(input))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(metaData, want) {
+			t.Errorf("%s: Actual [%+v], want [%+v]", name, metaData, want)
+		}
+		if string(md) != "# body" {
+			t.Errorf("%s: Actual [%q], want [%q]", name, md, "# body")
+		}
+	}
+}
+
+func TestParseFileBytesError(t *testing.T) {
+	cases := []struct {
+		input  string
+		line   int
+		format string
+	}{
+		{input: "---\ntitle: test\nweight: [\n---\n", line: 3, format: "yaml"},
+		{input: "---\ntitle: test\nweight: abc\n---\n", line: 3, format: "yaml"},
+		{input: "+++\ntitle = \"test\"\nweight = \n+++\n", line: 3, format: "toml"},
+		{input: "{\n  \"title\": \"test\",\n  \"weight\": \"abc\"\n}\n", line: 3, format: "json"},
+		{input: "{\n  \"title\": \"test\"\n  \"weight\": 2\n}\n", line: 3, format: "json"},
+	}
+	for _, c := range cases {
+		_, _, err := ParseFileBytes([]byte(c.input))
+		var lineErr *LineError
+		if !errors.As(err, &lineErr) || lineErr.Line != c.line {
+			t.Errorf("%q: Actual [%v], want line %d", c.input, err, c.line)
+			continue
+		}
+		if !strings.Contains(err.Error(), "invalid "+c.format+" front matter") {
+			t.Errorf("%q: Actual [%v], want %s format", c.input, err, c.format)
+		}
+	}
+}
+
+func TestAddWarning(t *testing.T) {
+	metaData := MetaData{BodyLine: 3}
+	metaData.AddWarning(2, "warning")
+	want := []Warning{{Line: 4, Message: "warning"}}
+	if !reflect.DeepEqual(metaData.Warnings, want) {
+		t.Errorf("Actual [%+v], want [%+v]", metaData.Warnings, want)
+	}
+}
+
+func TestLineError(t *testing.T) {
+	metaData := MetaData{BodyLine: 3}
+	cause := errors.New("invalid")
+	err := metaData.LineError(2, cause)
+
+	var lineErr *LineError
+	if !errors.As(err, &lineErr) || lineErr.Line != 4 {
+		t.Errorf("Actual [%+v], want line 4", err)
+	}
+	if !errors.Is(err, cause) {
+		t.Errorf("want wrapped error: %v", err)
+	}
+	if err.Error() != "line 4: invalid" {
+		t.Errorf("Actual [%s], want [line 4: invalid]", err.Error())
+	}
+}
+
+func TestPublishDates(t *testing.T) {
+	publish := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	expiry := time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC)
+	inputs := map[string]string{
+		"yaml":        "---\ndraft: true\npublishDate: 2024-01-02\nexpiryDate: 2024-03-04T10:00:00Z\n---\n",
+		"yaml string": "---\ndraft: true\npublishDate: \"2024-01-02\"\nexpiryDate: \"2024-03-04T10:00:00\"\n---\n",
+		"toml":        "+++\ndraft = true\npublishDate = 2024-01-02\nexpiryDate = 2024-03-04T10:00:00\n+++\n",
+		"json":        "{\n  \"draft\": true,\n  \"publishDate\": \"2024-01-02\",\n  \"expiryDate\": \"2024-03-04T10:00:00Z\"\n}\n",
+	}
+	for name, input := range inputs {
+		metaData, _, err := ParseFileBytes([]byte(input))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !metaData.Draft || !metaData.PublishDate.Equal(publish) || !metaData.ExpiryDate.Equal(expiry) || metaData.Params != nil {
+			t.Errorf("%s: Actual [%+v]", name, metaData)
+		}
+	}
+
+	_, _, err := ParseFileBytes([]byte("---\ntitle: a\npublishDate: tomorrow\n---\n"))
+	var lineErr *LineError
+	if !errors.As(err, &lineErr) || lineErr.Line != 3 {
+		t.Errorf("Actual [%v], want line 3", err)
+	}
+}
+
+func TestUnpublishedReason(t *testing.T) {
+	now := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		metaData MetaData
+		want     string
+	}{
+		{metaData: MetaData{}, want: ""},
+		{metaData: MetaData{Draft: true}, want: "draft"},
+		{metaData: MetaData{PublishDate: now}, want: ""},
+		{metaData: MetaData{PublishDate: now.Add(time.Hour)}, want: "scheduled for 2024-02-01T01:00:00Z"},
+		{metaData: MetaData{ExpiryDate: now.Add(time.Hour)}, want: ""},
+		{metaData: MetaData{ExpiryDate: now}, want: "expired on 2024-02-01T00:00:00Z"},
+	}
+	for _, c := range cases {
+		if actual := c.metaData.UnpublishedReason(now); actual != c.want {
+			t.Errorf("%+v: Actual [%s], want [%s]", c.metaData, actual, c.want)
+		}
+		if c.metaData.Published(now) != (c.want == "") {
+			t.Errorf("%+v: Published mismatch", c.metaData)
+		}
 	}
 }
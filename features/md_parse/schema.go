@@ -0,0 +1,243 @@
+package md_parse
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"golang.org/x/exp/slices"
+)
+
+// スキーマで指定できる値の型
+var schemaTypes = []string{"string", "int", "number", "bool", "date", "list", "map"}
+
+// typeがdateでformatが空の場合、publishDate等で受け付ける書式
+var defaultDateFormats = []string{"2006-01-02", time.RFC3339, "2006-01-02T15:04:05"}
+
+// フロントマターの1項目の規則
+type FieldRule struct {
+	// 真の場合、項目の記述を必須とする
+	Required bool `yaml:"required" toml:"required" json:"required"`
+	// 値の型(string, int, number, bool, date, list, map)。空の場合は問わない
+	Type string `yaml:"type" toml:"type" json:"type"`
+	// 許可する値の一覧。空の場合は問わない
+	Enum []any `yaml:"enum" toml:"enum" json:"enum"`
+	// typeがdateの場合の書式(time.Parseのレイアウト)。空の場合は2006-01-02、RFC3339等
+	Format string `yaml:"format" toml:"format" json:"format"`
+}
+
+// フロントマターのスキーマ
+type Schema struct {
+	// 項目名ごとの規則
+	Fields map[string]FieldRule `yaml:"fields" toml:"fields" json:"fields"`
+	// 真の場合、違反を警告ではなくエラーとする
+	Strict bool `yaml:"strict" toml:"strict" json:"strict"`
+	// ディレクトリ(マークダウンのディレクトリからの相対パス)ごとのスキーマ
+	// 配下のページには、上位のスキーマに項目を追加、上書きしたものを適用する
+	Sections map[string]Schema `yaml:"sections" toml:"sections" json:"sections"`
+}
+
+// スキーマへの違反
+type Violation struct {
+	Key string
+	// ファイル中の行番号(1始まり)
+	Line    int
+	Message string
+}
+
+func (v Violation) Error() string {
+	return fmt.Sprintf("key %q: %s", v.Key, v.Message)
+}
+
+// スキーマへの違反の一覧
+type SchemaError struct {
+	Violations []Violation
+}
+
+func (e *SchemaError) Error() string {
+	lines := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		lines = append(lines, fmt.Sprintf("line %d: %s", v.Line, v.Error()))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// スキーマの記述の誤り(未知の型等)を検出する
+func (s Schema) Check() error {
+	for _, key := range sortedKeys(s.Fields) {
+		rule := s.Fields[key]
+		if rule.Type != "" && !slices.Contains(schemaTypes, rule.Type) {
+			return fmt.Errorf("front matter schema %q: unknown type %q (want one of %s)", key, rule.Type, strings.Join(schemaTypes, ", "))
+		}
+		if rule.Format != "" && rule.Type != "date" {
+			return fmt.Errorf("front matter schema %q: format is only available for type date", key)
+		}
+	}
+	for _, dir := range sortedKeys(s.Sections) {
+		if err := s.Sections[dir].Check(); err != nil {
+			return fmt.Errorf("section %q: %w", dir, err)
+		}
+	}
+	return nil
+}
+
+// ページに適用するスキーマを返す。pagenameは拡張子なしのページ名(dir/page)
+// 該当する全てのセクションの項目を、浅いディレクトリから順に重ねる
+func (s Schema) ForPage(pagename string) Schema {
+	result := Schema{Fields: map[string]FieldRule{}, Strict: s.Strict}
+	for key, rule := range s.Fields {
+		result.Fields[key] = rule
+	}
+
+	dirs := sortedKeys(s.Sections)
+	sort.SliceStable(dirs, func(i, j int) bool {
+		return strings.Count(strings.Trim(dirs[i], "/"), "/") < strings.Count(strings.Trim(dirs[j], "/"), "/")
+	})
+	for _, dir := range dirs {
+		prefix := strings.Trim(dir, "/") + "/"
+		if !strings.HasPrefix(pagename, prefix) {
+			continue
+		}
+		section := s.Sections[dir].ForPage(strings.TrimPrefix(pagename, prefix))
+		for key, rule := range section.Fields {
+			result.Fields[key] = rule
+		}
+		result.Strict = result.Strict || section.Strict
+	}
+	return result
+}
+
+// フロントマターの全ての項目の値を検証し、違反を項目名の順に返す
+// keyLinesは項目名とファイル中の行番号。必須の項目が無い場合は1行目とする
+func (s Schema) validate(values map[string]any, keyLines map[string]int) []Violation {
+	var violations []Violation
+	for _, key := range sortedKeys(s.Fields) {
+		rule := s.Fields[key]
+		value, ok := values[key]
+		if !ok {
+			if rule.Required {
+				violations = append(violations, Violation{Key: key, Line: 1, Message: "required key is missing"})
+			}
+			continue
+		}
+
+		line := keyLines[key]
+		if line == 0 {
+			line = 1
+		}
+		if message := rule.check(value); message != "" {
+			violations = append(violations, Violation{Key: key, Line: line, Message: message})
+		}
+	}
+	return violations
+}
+
+// 値が規則に合わない場合は、その理由を返す
+func (r FieldRule) check(value any) string {
+	if r.Type != "" && !matchType(r.Type, r.Format, value) {
+		want := r.Type
+		if r.Type == "date" {
+			want += " (" + strings.Join(r.dateFormats(), " or ") + ")"
+		}
+		return fmt.Sprintf("want %s, got %s", want, describe(value))
+	}
+	if len(r.Enum) > 0 && !slices.ContainsFunc(r.Enum, func(e any) bool { return fmt.Sprint(e) == fmt.Sprint(value) }) {
+		allowed := make([]string, 0, len(r.Enum))
+		for _, e := range r.Enum {
+			allowed = append(allowed, fmt.Sprintf("%v", e))
+		}
+		return fmt.Sprintf("%s is not one of [%s]", describe(value), strings.Join(allowed, ", "))
+	}
+	return ""
+}
+
+func (r FieldRule) dateFormats() []string {
+	if r.Format != "" {
+		return []string{r.Format}
+	}
+	return defaultDateFormats
+}
+
+// 値が型に合うか。数値はyaml, toml, jsonのいずれで読み取った値も扱う
+func matchType(typeName string, format string, value any) bool {
+	switch typeName {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "int":
+		switch v := value.(type) {
+		case int, int64, uint64:
+			return true
+		case float64:
+			return v == math.Trunc(v)
+		}
+		return false
+	case "number":
+		switch value.(type) {
+		case int, int64, uint64, float64:
+			return true
+		}
+		return false
+	case "bool":
+		_, ok := value.(bool)
+		return ok
+	case "date":
+		_, err := parseDate(value, (FieldRule{Format: format}).dateFormats())
+		return err == nil
+	case "list":
+		_, ok := value.([]any)
+		return ok
+	case "map":
+		_, ok := value.(map[string]any)
+		return ok
+	}
+	return true
+}
+
+// フロントマターの日時の値を変換する
+// yaml, tomlの日時の値はそのまま、文字列はlayoutsのいずれかで解釈する。タイムゾーンの無い値はUTCとする
+func parseDate(value any, layouts []string) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case toml.LocalDate:
+		return v.AsTime(time.UTC), nil
+	case toml.LocalDateTime:
+		return v.AsTime(time.UTC), nil
+	case string:
+		for _, layout := range layouts {
+			if date, err := time.Parse(layout, v); err == nil {
+				return date, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("cannot parse %q as a date (%s)", v, strings.Join(layouts, " or "))
+	}
+	return time.Time{}, fmt.Errorf("want a date, got %s", describe(value))
+}
+
+// エラーメッセージ中の値の表記
+func describe(value any) string {
+	switch v := value.(type) {
+	case string:
+		return fmt.Sprintf("%q", v)
+	case []any:
+		return "list"
+	case map[string]any:
+		return "map"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
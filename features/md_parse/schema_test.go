@@ -0,0 +1,110 @@
+package md_parse
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var testSchema = Schema{
+	Fields: map[string]FieldRule{
+		"title": {Required: true, Type: "string"},
+	},
+	Sections: map[string]Schema{
+		"blog": {
+			Fields: map[string]FieldRule{
+				"date":   {Required: true, Type: "date"},
+				"status": {Enum: []any{"draft", "published"}},
+				"tags":   {Type: "list"},
+			},
+			Sections: map[string]Schema{
+				"news": {Fields: map[string]FieldRule{
+					"date": {Type: "date", Format: "2006/01/02"},
+				}},
+			},
+		},
+	},
+}
+
+func TestSchemaForPage(t *testing.T) {
+	if schema := testSchema.ForPage("about"); !reflect.DeepEqual(schema.Fields, testSchema.Fields) {
+		t.Errorf("Actual [%+v], want [%+v]", schema.Fields, testSchema.Fields)
+	}
+	if schema := testSchema.ForPage("blog/post"); len(schema.Fields) != 4 || !schema.Fields["date"].Required {
+		t.Errorf("Actual [%+v], want title, date, status, tags", schema.Fields)
+	}
+	// 深いセクションの項目で上書きする
+	schema := testSchema.ForPage("blog/news/post")
+	want := FieldRule{Type: "date", Format: "2006/01/02"}
+	if !reflect.DeepEqual(schema.Fields["date"], want) {
+		t.Errorf("Actual [%+v], want [%+v]", schema.Fields["date"], want)
+	}
+}
+
+func TestSchemaCheck(t *testing.T) {
+	if err := testSchema.Check(); err != nil {
+		t.Error(err)
+	}
+	invalid := Schema{Sections: map[string]Schema{"blog": {Fields: map[string]FieldRule{"date": {Type: "datetime"}}}}}
+	if err := invalid.Check(); err == nil {
+		t.Error("want error for unknown type")
+	}
+	invalid = Schema{Fields: map[string]FieldRule{"title": {Type: "string", Format: "2006"}}}
+	if err := invalid.Check(); err == nil {
+		t.Error("want error for format of non-date type")
+	}
+}
+
+func TestParseFileBytesWithSchema(t *testing.T) {
+	input := "---\n" +
+		"title: 1\n" +
+		"status: archived\n" +
+		"tags: go\n" +
+		"---\n" +
+		"# body\n"
+	metaData, _, err := ParseFileBytesWithSchema([]byte(input), testSchema.ForPage("blog/post"))
+	if err != nil {
+		t.Error(err)
+	}
+	want := []Warning{
+		{Line: 1, Message: `key "date": required key is missing`, Source: WarningSourceFrontMatter},
+		{Line: 3, Message: `key "status": "archived" is not one of [draft, published]`, Source: WarningSourceFrontMatter},
+		{Line: 4, Message: `key "tags": want list, got "go"`, Source: WarningSourceFrontMatter},
+		{Line: 2, Message: `key "title": want string, got 1`, Source: WarningSourceFrontMatter},
+	}
+	if !reflect.DeepEqual(metaData.Warnings, want) {
+		t.Errorf("Actual [%+v], want [%+v]", metaData.Warnings, want)
+	}
+
+	// 違反が無い場合
+	valid := map[string]string{
+		"yaml":            "---\ntitle: a\ndate: 2024-01-02\nstatus: draft\ntags: [a]\n---\n",
+		"toml":            "+++\ntitle = \"a\"\ndate = 2024-01-02T10:00:00Z\nstatus = \"draft\"\n+++\n",
+		"json":            "{\n  \"title\": \"a\",\n  \"date\": \"2024-01-02\"\n}\n",
+		"toml local date": "+++\ntitle = \"a\"\ndate = 2024-01-02\n+++\n",
+	}
+	for name, input := range valid {
+		metaData, _, err := ParseFileBytesWithSchema([]byte(input), testSchema.ForPage("blog/post"))
+		if err != nil || len(metaData.Warnings) > 0 {
+			t.Errorf("%s: Actual [%v, %+v], want no violations", name, err, metaData.Warnings)
+		}
+	}
+}
+
+func TestParseFileBytesWithSchemaStrict(t *testing.T) {
+	schema := testSchema.ForPage("blog/news/post")
+	schema.Strict = true
+	input := "+++\n" +
+		"title = \"a\"\n" +
+		"date = \"2024-01-02\"\n" +
+		"+++\n"
+	_, _, err := ParseFileBytesWithSchema([]byte(input), schema)
+	var schemaErr *SchemaError
+	if !errors.As(err, &schemaErr) {
+		t.Fatalf("Actual [%v], want *SchemaError", err)
+	}
+	want := []Violation{{Key: "date", Line: 3, Message: `want date (2006/01/02), got "2024-01-02"`}}
+	if !reflect.DeepEqual(schemaErr.Violations, want) {
+		t.Errorf("Actual [%+v], want [%+v]", schemaErr.Violations, want)
+	}
+}
@@ -0,0 +1,111 @@
+package scaffold
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// 生成するファイルのパス(ディレクトリからの相対)と内容
+type file struct {
+	path     string
+	contents string
+}
+
+const indexMd = `---
+title: "Home"
+overview: "ssgenで生成したサイト"
+---
+# Home
+
+マークダウンを編集し、サイトを構築してください。
+`
+
+const headerMd = `# [{Home|index}]
+`
+
+const sidebarMd = `<!-- ナビゲーション以外にサイドバーへ表示する内容 -->
+`
+
+const footerMd = `Generated by ssgen
+`
+
+const indexHtml = `<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>{{.title}}</title>
+  <link rel="stylesheet" href="{{.assets_path}}/style.css">
+</head>
+<body>
+  <header>{{.header}}</header>
+  <nav class="breadcrumbs">
+    {{- range .breadcrumbs}}
+    {{if and .Url (not .IsCurrent)}}<a href="{{.Url}}">{{.Title}}</a>{{else}}<span{{if .IsCurrent}} aria-current="page"{{end}}>{{.Title}}</span>{{end}}
+    {{- end}}
+  </nav>
+  <aside>
+    <nav class="site-nav">{{template "nav" .nav}}</nav>
+    {{.sidebar}}
+    {{renderTOC .toc}}
+  </aside>
+  <main>
+    {{.overview}}
+    {{.content}}
+    <nav class="pager">
+      {{- with .prev}}<a rel="prev" href="{{.Url}}">&larr; {{.Title}}</a>{{end}}
+      {{- with .next}}<a rel="next" href="{{.Url}}">{{.Title}} &rarr;</a>{{end}}
+    </nav>
+  </main>
+  <footer>{{.footer}}</footer>
+</body>
+</html>
+{{define "nav"}}<ul>
+  {{- range .}}
+  <li{{if .Active}} class="active"{{end}}>
+    {{- if .Url}}<a href="{{.Url}}">{{.Title}}</a>{{else}}<span>{{.Title}}</span>{{end}}
+    {{- if and .IsSection (or .Expanded .Active)}}{{template "nav" .Children}}{{end -}}
+  </li>
+  {{- end}}
+</ul>{{end}}
+`
+
+const styleCss = `body {
+  margin: 0 auto;
+  max-width: 960px;
+  font-family: sans-serif;
+}
+`
+
+// dir以下に、サイトの雛形となるマークダウン、テンプレート、アセッツを作成する
+// 既に存在するファイルは上書きせずエラーとする
+func Create(dir string, mdBaseDir string, assetsPath string, templateDir string) error {
+	mdLayoutDir := filepath.Join(mdBaseDir, "layout")
+	files := []file{
+		{path: filepath.Join(mdBaseDir, "index.md"), contents: indexMd},
+		{path: filepath.Join(mdLayoutDir, "_header.md"), contents: headerMd},
+		{path: filepath.Join(mdLayoutDir, "_sidebar.md"), contents: sidebarMd},
+		{path: filepath.Join(mdLayoutDir, "_footer.md"), contents: footerMd},
+		{path: filepath.Join(templateDir, "index.html"), contents: indexHtml},
+		{path: filepath.Join(assetsPath, "style.css"), contents: styleCss},
+	}
+
+	// 一部だけ作成された状態とならないよう、先に存在チェックを行う
+	for _, f := range files {
+		path := filepath.Join(dir, f.path)
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("already exists: %s", path)
+		}
+	}
+
+	for _, f := range files {
+		path := filepath.Join(dir, f.path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(f.contents), 0666); err != nil {
+			return err
+		}
+	}
+	return nil
+}
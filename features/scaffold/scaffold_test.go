@@ -0,0 +1,37 @@
+package scaffold
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TwilightUncle/ssgen/helpers/testing_helper"
+)
+
+func TestCreate(t *testing.T) {
+	baseDir := filepath.Join(os.TempDir(), "github.com/TwilightUncle/ssgen-scaffold_test-"+testing_helper.MakeRandomStr(32))
+	testing_helper.MakeTestFiles(baseDir, []testing_helper.TestFileData{}, t)
+
+	if err := Create(baseDir, "md", "assets", "templates"); err != nil {
+		t.Fatal(err)
+	}
+
+	wantFiles := []string{
+		filepath.Join(baseDir, "md", "index.md"),
+		filepath.Join(baseDir, "md", "layout", "_header.md"),
+		filepath.Join(baseDir, "md", "layout", "_sidebar.md"),
+		filepath.Join(baseDir, "md", "layout", "_footer.md"),
+		filepath.Join(baseDir, "templates", "index.html"),
+		filepath.Join(baseDir, "assets", "style.css"),
+	}
+	for _, path := range wantFiles {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("want exists: %s", path)
+		}
+	}
+
+	// 既存ファイルは上書きしないこと
+	if err := Create(baseDir, "md", "assets", "templates"); err == nil {
+		t.Errorf("want error when files already exist")
+	}
+}
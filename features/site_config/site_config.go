@@ -0,0 +1,214 @@
+package site_config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/TwilightUncle/ssgen/features/md_parse"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// 作業ディレクトリから探索する設定ファイル名(先頭のものが優先)
+var FileNames = []string{"ssgen.yaml", "ssgen.yml", "ssgen.toml", "ssgen.json"}
+
+// サイト全体の設定
+type Config struct {
+	// 空文字(ルート直下)も有効な値であるため、記述の有無をnilで区別する
+	BaseUrl          *string `yaml:"baseUrl" toml:"baseUrl" json:"baseUrl"`
+	MdBaseDir        string  `yaml:"mdBaseDir" toml:"mdBaseDir" json:"mdBaseDir"`
+	AssetsPath       string  `yaml:"assetsPath" toml:"assetsPath" json:"assetsPath"`
+	TemplateDir      string  `yaml:"templateDir" toml:"templateDir" json:"templateDir"`
+	TemplateHtmlName string  `yaml:"templateHtmlName" toml:"templateHtmlName" json:"templateHtmlName"`
+	OutputDir        string  `yaml:"outputDir" toml:"outputDir" json:"outputDir"`
+	UrlSuffix        string  `yaml:"urlSuffix" toml:"urlSuffix" json:"urlSuffix"`
+	CachePath        string  `yaml:"cachePath" toml:"cachePath" json:"cachePath"`
+
+	// テンプレートから参照可能な、サイト全体の任意の値
+	Params map[string]any `yaml:"params" toml:"params" json:"params"`
+
+	// ページのフロントマターを検証するスキーマ
+	FrontMatter *md_parse.Schema `yaml:"frontMatter" toml:"frontMatter" json:"frontMatter"`
+
+	// 環境名をキーにした上書き設定
+	Environments map[string]Override `yaml:"environments" toml:"environments" json:"environments"`
+}
+
+// 環境ごとの上書き設定。指定された項目のみ上書きする
+type Override struct {
+	BaseUrl          *string        `yaml:"baseUrl" toml:"baseUrl" json:"baseUrl"`
+	MdBaseDir        *string        `yaml:"mdBaseDir" toml:"mdBaseDir" json:"mdBaseDir"`
+	AssetsPath       *string        `yaml:"assetsPath" toml:"assetsPath" json:"assetsPath"`
+	TemplateDir      *string        `yaml:"templateDir" toml:"templateDir" json:"templateDir"`
+	TemplateHtmlName *string        `yaml:"templateHtmlName" toml:"templateHtmlName" json:"templateHtmlName"`
+	OutputDir        *string        `yaml:"outputDir" toml:"outputDir" json:"outputDir"`
+	UrlSuffix        *string        `yaml:"urlSuffix" toml:"urlSuffix" json:"urlSuffix"`
+	CachePath        *string        `yaml:"cachePath" toml:"cachePath" json:"cachePath"`
+	Params           map[string]any `yaml:"params" toml:"params" json:"params"`
+}
+
+// dir以下に存在する設定ファイルのパスを返す。存在しない場合は空文字
+func Find(dir string) (string, error) {
+	for _, name := range FileNames {
+		path := filepath.Join(dir, name)
+		info, err := os.Stat(path)
+		if err == nil && !info.IsDir() {
+			return path, nil
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// 設定ファイルを読み込み、envに該当する上書き設定を適用した結果を返す
+// 形式は拡張子(.yaml, .yml, .toml, .json)により判定する
+func Load(path string, env string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	var cfg Config
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = decodeYaml(path, data, &cfg)
+	case ".toml":
+		err = decodeToml(path, data, &cfg)
+	case ".json":
+		err = decodeJson(path, data, &cfg)
+	default:
+		err = fmt.Errorf("%s: unsupported config format %q", path, ext)
+	}
+	if err != nil {
+		return Config{}, err
+	}
+
+	if env != "" {
+		if override, ok := cfg.Environments[env]; ok {
+			cfg.apply(override)
+		}
+	}
+	return cfg, nil
+}
+
+// 上書き設定の適用
+func (cfg *Config) apply(o Override) {
+	if o.BaseUrl != nil {
+		cfg.BaseUrl = o.BaseUrl
+	}
+	setIfNotNil(&cfg.MdBaseDir, o.MdBaseDir)
+	setIfNotNil(&cfg.AssetsPath, o.AssetsPath)
+	setIfNotNil(&cfg.TemplateDir, o.TemplateDir)
+	setIfNotNil(&cfg.TemplateHtmlName, o.TemplateHtmlName)
+	setIfNotNil(&cfg.OutputDir, o.OutputDir)
+	setIfNotNil(&cfg.UrlSuffix, o.UrlSuffix)
+	setIfNotNil(&cfg.CachePath, o.CachePath)
+
+	if len(o.Params) == 0 {
+		return
+	}
+	params := map[string]any{}
+	for k, v := range cfg.Params {
+		params[k] = v
+	}
+	for k, v := range o.Params {
+		params[k] = v
+	}
+	cfg.Params = params
+}
+
+func setIfNotNil(dest *string, src *string) {
+	if src != nil {
+		*dest = *src
+	}
+}
+
+// 未知のキーを許容せずにyamlを読み込む
+func decodeYaml(path string, data []byte, cfg *Config) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	err := dec.Decode(cfg)
+	if err == nil || err == io.EOF {
+		return nil
+	}
+
+	// 'line N: ...' を 'path:N: ...' の形式へ変換
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		msgs := make([]string, 0, len(typeErr.Errors))
+		for _, msg := range typeErr.Errors {
+			if line, text, ok := md_parse.SplitYamlErrLine(msg); ok {
+				msgs = append(msgs, fmt.Sprintf("%s:%d: %s", path, line, text))
+				continue
+			}
+			msgs = append(msgs, fmt.Sprintf("%s: %s", path, msg))
+		}
+		return errors.New(strings.Join(msgs, "\n"))
+	}
+	return fmt.Errorf("%s: %w", path, err)
+}
+
+// 未知のキーを許容せずにtomlを読み込む
+func decodeToml(path string, data []byte, cfg *Config) error {
+	err := toml.NewDecoder(bytes.NewReader(data)).DisallowUnknownFields().Decode(cfg)
+	if err == nil {
+		return nil
+	}
+
+	var strictErr *toml.StrictMissingError
+	if errors.As(err, &strictErr) {
+		msgs := make([]string, 0, len(strictErr.Errors))
+		for _, e := range strictErr.Errors {
+			row, _ := e.Position()
+			msgs = append(msgs, fmt.Sprintf("%s:%d: unknown key %q", path, row, strings.Join(e.Key(), ".")))
+		}
+		return errors.New(strings.Join(msgs, "\n"))
+	}
+
+	var decodeErr *toml.DecodeError
+	if errors.As(err, &decodeErr) {
+		row, _ := decodeErr.Position()
+		return fmt.Errorf("%s:%d: %s", path, row, decodeErr.Error())
+	}
+	return fmt.Errorf("%s: %w", path, err)
+}
+
+// 未知のキーを許容せずにjsonを読み込む
+func decodeJson(path string, data []byte, cfg *Config) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(cfg)
+	if err == nil {
+		return nil
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return fmt.Errorf("%s:%d: %s", path, md_parse.LineOf(data, syntaxErr.Offset), syntaxErr.Error())
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return fmt.Errorf("%s:%d: %s", path, md_parse.LineOf(data, typeErr.Offset), typeErr.Error())
+	}
+
+	// 未知のキーは位置が得られないため、キーの出現位置から行を求める
+	const unknownPrefix = "json: unknown field "
+	if msg := err.Error(); strings.HasPrefix(msg, unknownPrefix) {
+		key, _ := strconv.Unquote(strings.TrimPrefix(msg, unknownPrefix))
+		offset := bytes.Index(data, []byte(strconv.Quote(key)))
+		if offset >= 0 {
+			return fmt.Errorf("%s:%d: unknown key %q", path, md_parse.LineOf(data, int64(offset)), key)
+		}
+		return fmt.Errorf("%s: unknown key %q", path, key)
+	}
+	return fmt.Errorf("%s: %w", path, err)
+}
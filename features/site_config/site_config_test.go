@@ -0,0 +1,154 @@
+package site_config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TwilightUncle/ssgen/helpers/testing_helper"
+)
+
+const yamlConfig = `baseUrl: "https://example.com"
+mdBaseDir: md
+outputDir: public
+params:
+  siteName: "test site"
+environments:
+  development:
+    baseUrl: "http://localhost:8080"
+    params:
+      siteName: "dev site"
+`
+
+const tomlConfig = `baseUrl = "https://example.com"
+mdBaseDir = "md"
+
+[params]
+siteName = "test site"
+
+[environments.development]
+baseUrl = "http://localhost:8080"
+`
+
+const jsonConfig = `{
+  "baseUrl": "https://example.com",
+  "mdBaseDir": "md",
+  "params": {"siteName": "test site"},
+  "environments": {"development": {"baseUrl": "http://localhost:8080"}}
+}`
+
+func makeConfigDir(t *testing.T, data []testing_helper.TestFileData) string {
+	baseDir := filepath.Join(os.TempDir(), "github.com/TwilightUncle/ssgen-site_config_test-"+testing_helper.MakeRandomStr(32))
+	for i := range data {
+		data[i].Path = filepath.Join(baseDir, data[i].Path)
+	}
+	testing_helper.MakeTestFiles(baseDir, data, t)
+	return baseDir
+}
+
+func TestFind(t *testing.T) {
+	baseDir := makeConfigDir(t, []testing_helper.TestFileData{
+		{Path: "ssgen.json", Contents: []byte(jsonConfig)},
+		{Path: "ssgen.yaml", Contents: []byte(yamlConfig)},
+	})
+
+	// yamlが優先されること
+	path, err := Find(baseDir)
+	if err != nil {
+		t.Error(err)
+	}
+	if want := filepath.Join(baseDir, "ssgen.yaml"); path != want {
+		t.Errorf("Actual [%s], want [%s]", path, want)
+	}
+
+	// 存在しない場合は空文字
+	if path, err = Find(filepath.Join(baseDir, "none")); path != "" || err != nil {
+		t.Errorf("Actual [%s, %v], want empty", path, err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	baseDir := makeConfigDir(t, []testing_helper.TestFileData{
+		{Path: "ssgen.yaml", Contents: []byte(yamlConfig)},
+		{Path: "ssgen.toml", Contents: []byte(tomlConfig)},
+		{Path: "ssgen.json", Contents: []byte(jsonConfig)},
+	})
+
+	for _, name := range []string{"ssgen.yaml", "ssgen.toml", "ssgen.json"} {
+		cfg, err := Load(filepath.Join(baseDir, name), "")
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if cfg.BaseUrl == nil || *cfg.BaseUrl != "https://example.com" || cfg.MdBaseDir != "md" {
+			t.Errorf("%s: Actual [%+v]", name, cfg)
+		}
+		if cfg.Params["siteName"] != "test site" {
+			t.Errorf("%s: Actual params [%+v]", name, cfg.Params)
+		}
+
+		// 環境ごとの上書き
+		cfg, err = Load(filepath.Join(baseDir, name), "development")
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if cfg.BaseUrl == nil || *cfg.BaseUrl != "http://localhost:8080" || cfg.MdBaseDir != "md" {
+			t.Errorf("%s: Actual [%+v]", name, cfg)
+		}
+	}
+
+	// paramsはキー単位で上書きされること
+	cfg, _ := Load(filepath.Join(baseDir, "ssgen.yaml"), "development")
+	if cfg.Params["siteName"] != "dev site" {
+		t.Errorf("Actual params [%+v]", cfg.Params)
+	}
+}
+
+func TestLoadUnknownKeys(t *testing.T) {
+	baseDir := makeConfigDir(t, []testing_helper.TestFileData{
+		{Path: "ssgen.yaml", Contents: []byte("baseUrl: a\nbaseUri: b\n")},
+		{Path: "ssgen.toml", Contents: []byte("baseUrl = \"a\"\n\nbaseUri = \"b\"\n")},
+		{Path: "ssgen.json", Contents: []byte("{\n  \"baseUrl\": \"a\",\n  \"baseUri\": \"b\"\n}")},
+	})
+
+	wants := map[string]string{
+		"ssgen.yaml": "ssgen.yaml:2:",
+		"ssgen.toml": "ssgen.toml:3:",
+		"ssgen.json": "ssgen.json:3:",
+	}
+	for name, want := range wants {
+		_, err := Load(filepath.Join(baseDir, name), "")
+		if err == nil {
+			t.Errorf("%s: want error", name)
+			continue
+		}
+		if !strings.Contains(err.Error(), want) || !strings.Contains(err.Error(), "baseUri") {
+			t.Errorf("%s: Actual [%v], want contains [%s]", name, err, want)
+		}
+	}
+}
+
+func TestLoadFrontMatter(t *testing.T) {
+	baseDir := makeConfigDir(t, []testing_helper.TestFileData{
+		{Path: "ssgen.yaml", Contents: []byte("frontMatter:\n  strict: true\n  fields:\n    title: {required: true, type: string}\n  sections:\n    blog:\n      fields:\n        status: {enum: [draft, published]}\n")},
+		{Path: "ssgen.toml", Contents: []byte("[frontMatter]\nstrict = true\n\n[frontMatter.fields.title]\nrequired = true\ntype = \"string\"\n\n[frontMatter.sections.blog.fields.status]\nenum = [\"draft\", \"published\"]\n")},
+		{Path: "ssgen.json", Contents: []byte(`{"frontMatter": {"strict": true, "fields": {"title": {"required": true, "type": "string"}}, "sections": {"blog": {"fields": {"status": {"enum": ["draft", "published"]}}}}}}`)},
+	})
+
+	for _, name := range []string{"ssgen.yaml", "ssgen.toml", "ssgen.json"} {
+		cfg, err := Load(filepath.Join(baseDir, name), "")
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if cfg.BaseUrl != nil {
+			t.Errorf("%s: Actual [%v], want nil baseUrl", name, *cfg.BaseUrl)
+		}
+		schema := cfg.FrontMatter
+		if schema == nil || !schema.Strict || !schema.Fields["title"].Required || len(schema.Sections["blog"].Fields["status"].Enum) != 2 {
+			t.Errorf("%s: Actual [%+v]", name, schema)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package slug
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// 見出しの内容からidを生成する関数
+type Func func(text string) string
+
+// GitHubと互換のslugを生成する
+// 小文字化したうえで、文字、数字、'-'、'_'、空白以外を取り除き、空白を'-'に置き換える
+func GitHub(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(text)) {
+		switch {
+		case r == ' ':
+			b.WriteRune('-')
+		case r == '-', r == '_',
+			unicode.IsLetter(r), unicode.IsNumber(r), unicode.IsMark(r),
+			unicode.Is(unicode.Pc, r):
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// ページ内でidが重複しないように、2つ目以降へ-1, -2...を付与する
+// New関数により生成すること
+type Slugger struct {
+	fn Func
+	// 生成済みのidと、その重複回数
+	occurrences map[string]int
+}
+
+// fnがnilの場合はGitHubを用いる
+func New(fn Func) *Slugger {
+	if fn == nil {
+		fn = GitHub
+	}
+	return &Slugger{fn: fn, occurrences: map[string]int{}}
+}
+
+// 見出しの内容から、ページ内で一意なidを生成する
+func (s *Slugger) Slug(text string) string {
+	return s.Unique(s.fn(text))
+}
+
+// idをページ内で一意なものとして登録する。既に存在する場合は-1, -2...を付与したものを返す
+func (s *Slugger) Unique(id string) string {
+	result := id
+	for {
+		if _, ok := s.occurrences[result]; !ok {
+			break
+		}
+		s.occurrences[id]++
+		result = id + "-" + strconv.Itoa(s.occurrences[id])
+	}
+	s.occurrences[result] = 0
+	return result
+}
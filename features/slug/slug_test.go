@@ -0,0 +1,50 @@
+package slug
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGitHub(t *testing.T) {
+	cases := map[string]string{
+		"Hello World":          "hello-world",
+		"  Trim  me ":          "trim--me",
+		"C++ & Go: 101!":       "c--go-101",
+		"snake_case-and-kebab": "snake_case-and-kebab",
+		"インストール 方法":            "インストール-方法",
+		"Ünïcödé Letters":      "ünïcödé-letters",
+		"What's new? (v1.2.3)": "whats-new-v123",
+	}
+	for text, want := range cases {
+		if actual := GitHub(text); actual != want {
+			t.Errorf("%q: Actual [%s], want [%s]", text, actual, want)
+		}
+	}
+}
+
+func TestSlugger(t *testing.T) {
+	s := New(nil)
+	actual := []string{
+		s.Slug("Intro"),
+		s.Slug("Intro"),
+		s.Slug("Intro"),
+		// 生成済みのidと同じ内容の見出し
+		s.Slug("Intro 1"),
+		s.Unique("custom"),
+		s.Slug("Custom"),
+	}
+	want := []string{"intro", "intro-1", "intro-2", "intro-1-1", "custom", "custom-1"}
+	if strings.Join(actual, ",") != strings.Join(want, ",") {
+		t.Errorf("Actual [%v], want [%v]", actual, want)
+	}
+}
+
+func TestSluggerWithFunc(t *testing.T) {
+	s := New(strings.ToUpper)
+	if actual := s.Slug("a"); actual != "A" {
+		t.Errorf("Actual [%s], want [A]", actual)
+	}
+	if actual := s.Slug("a"); actual != "A-1" {
+		t.Errorf("Actual [%s], want [A-1]", actual)
+	}
+}
@@ -0,0 +1,113 @@
+package watch
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// 変更検知に用いるファイルの状態
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// 指定したディレクトリ以下のファイルの追加、更新、削除をポーリングにより検知する
+// New関数により生成すること
+type Watcher struct {
+	paths    []string
+	interval time.Duration
+	states   map[string]fileState
+}
+
+func New(paths []string, interval time.Duration) (*Watcher, error) {
+	w := &Watcher{paths: paths, interval: interval}
+	states, err := w.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	w.states = states
+	return w, nil
+}
+
+// 前回の確認以降に追加、更新、削除されたファイルパスを返す
+func (w *Watcher) Poll() ([]string, error) {
+	states, err := w.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	changed := []string{}
+	for path, state := range states {
+		if prev, ok := w.states[path]; !ok || prev != state {
+			changed = append(changed, path)
+		}
+	}
+	for path := range w.states {
+		if _, ok := states[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+
+	w.states = states
+	return changed, nil
+}
+
+// ctxが終了するまでintervalごとに確認を行い、変更があればfnを呼び出す
+// 確認に失敗した場合はerrFnを呼び出し、監視を継続する
+func (w *Watcher) Run(ctx context.Context, fn func(changed []string), errFn func(err error)) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		changed, err := w.Poll()
+		if err != nil {
+			errFn(err)
+			continue
+		}
+		if len(changed) > 0 {
+			fn(changed)
+		}
+	}
+}
+
+// 監視対象の全ファイルの状態を取得。存在しない監視対象は無視する
+func (w *Watcher) snapshot() (map[string]fileState, error) {
+	states := map[string]fileState{}
+	for _, root := range w.paths {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			states[path] = fileState{modTime: info.ModTime(), size: info.Size()}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return states, nil
+}
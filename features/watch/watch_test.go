@@ -0,0 +1,62 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TwilightUncle/ssgen/helpers/testing_helper"
+)
+
+func TestPoll(t *testing.T) {
+	baseDir := filepath.Join(os.TempDir(), "github.com/TwilightUncle/ssgen-watch_test-"+testing_helper.MakeRandomStr(32))
+	testing_helper.MakeTestFiles(baseDir, []testing_helper.TestFileData{
+		{Path: filepath.Join(baseDir, "a.md"), Contents: []byte("a")},
+		{Path: filepath.Join(baseDir, "sub", "b.md"), Contents: []byte("b")},
+	}, t)
+
+	w, err := New([]string{baseDir, filepath.Join(baseDir, "not_exists")}, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 変更なし
+	if changed, err := w.Poll(); err != nil || len(changed) != 0 {
+		t.Errorf("Actual [%v, %v], want no changes", changed, err)
+	}
+
+	// 更新、追加、削除
+	if err := os.WriteFile(filepath.Join(baseDir, "a.md"), []byte("aa"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(baseDir, "sub", "c.md"), []byte("c"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(baseDir, "sub", "b.md")); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := w.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		filepath.Join(baseDir, "a.md"),
+		filepath.Join(baseDir, "sub", "b.md"),
+		filepath.Join(baseDir, "sub", "c.md"),
+	}
+	if len(changed) != len(want) {
+		t.Fatalf("Actual [%v], want [%v]", changed, want)
+	}
+	for i := range want {
+		if changed[i] != want[i] {
+			t.Errorf("Actual [%s], want [%s]", changed[i], want[i])
+		}
+	}
+
+	// 確認済みの変更は再度検知しない
+	if changed, err := w.Poll(); err != nil || len(changed) != 0 {
+		t.Errorf("Actual [%v, %v], want no changes", changed, err)
+	}
+}
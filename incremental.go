@@ -0,0 +1,152 @@
+package ssgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/TwilightUncle/ssgen/features/build_cache"
+	"github.com/TwilightUncle/ssgen/features/md_parse"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 差分ビルドの状態
+type incrementalBuild struct {
+	// キャッシュファイルのパス。空の場合は差分ビルドを行わない
+	cachePath string
+	// 全ページに共通する入力(テンプレート、レイアウト部品、ページ一覧等)のハッシュ
+	globalKey string
+	prev      *build_cache.Cache
+	// 並行して出力が記録されるため、mu で保護する
+	mu   sync.Mutex
+	next *build_cache.Cache
+}
+
+// 前回のキャッシュを読み込み、差分ビルドを開始する
+// キャッシュファイルが指定されていない場合や、レイアウトの構築方法が不明な場合は全てを出力し直す
+func (s *Site) newIncrementalBuild() (*incrementalBuild, error) {
+	b := &incrementalBuild{
+		prev: build_cache.New(s.OutputDir),
+		next: build_cache.New(s.OutputDir),
+	}
+	if s.CachePath == "" || s.layoutDigest == "" {
+		return b, nil
+	}
+
+	prev, err := build_cache.Load(s.CachePath, s.OutputDir)
+	if err != nil {
+		return nil, err
+	}
+	globalKey, err := s.globalCacheKey()
+	if err != nil {
+		return nil, err
+	}
+
+	b.cachePath = s.CachePath
+	b.globalKey = globalKey
+	b.prev = prev
+	return b, nil
+}
+
+// 全ページに共通する入力のハッシュ
+func (s *Site) globalCacheKey() (string, error) {
+	// 読み込めない場合はテンプレートの解析時にエラーとなる
+	tmpl, _ := os.ReadFile(s.templatePath())
+
+	params, err := json.Marshal(s.Params)
+	if err != nil {
+		return "", fmt.Errorf("params: %w", err)
+	}
+
+	// パンくずリスト等はページの一覧に依存する
+	pages := make([]string, 0, len(s.MdPaths.GetAll()))
+	for _, mdPath := range s.MdPaths.GetAll() {
+		pages = append(pages, s.MdPaths.GetPageName(mdPath))
+	}
+
+	return build_cache.Hash(
+		tmpl,
+		[]byte(s.layoutDigest),
+		[]byte(s.pagesDigest),
+		[]byte(strings.Join(pages, "\n")),
+		[]byte(s.BaseUrl),
+		[]byte(s.UrlSuffix),
+		[]byte(s.AssetsPath),
+		// 目次の深さはミドルウェア、レイアウトの双方に影響する
+		[]byte(strconv.Itoa(s.TOCMinDepth)+"-"+strconv.Itoa(s.TOCMaxDepth)),
+		params,
+	), nil
+}
+
+// 前回の出力が残っており、再利用できるか
+func (b *incrementalBuild) reusable() bool {
+	return b.cachePath != "" && len(b.prev.Entries) > 0
+}
+
+// ページの入力のハッシュ。全ページ共通の入力を含む
+func (b *incrementalBuild) pageKey(parts ...[]byte) string {
+	return build_cache.Hash(append([][]byte{[]byte(b.globalKey)}, parts...)...)
+}
+
+// 今回の出力を記録し、前回と同じ入力で出力済みであるかを返す
+func (b *incrementalBuild) fresh(source string, key string, output string) bool {
+	b.mu.Lock()
+	b.next.Entries[source] = build_cache.Entry{Key: key, Output: output}
+	b.mu.Unlock()
+	return b.cachePath != "" && b.prev.Fresh(source, key)
+}
+
+// 出力に失敗した入力を、次回のビルドで再度出力させる
+func (b *incrementalBuild) discard(source string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// 前回の出力は残し、キーの不一致により再度出力させる
+	if entry, ok := b.prev.Entries[source]; ok {
+		b.next.Entries[source] = build_cache.Entry{Output: entry.Output}
+		return
+	}
+	delete(b.next.Entries, source)
+}
+
+// 削除された入力に対応する出力を取り除き、キャッシュを保存する
+func (b *incrementalBuild) finish() error {
+	if b.cachePath == "" {
+		return nil
+	}
+	for _, output := range b.prev.Stale(b.next) {
+		if err := os.Remove(output); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return b.next.Save(b.cachePath)
+}
+
+// 変換済みのレイアウト部品のハッシュ
+func layoutDigest(ginH gin.H) string {
+	parts := [][]byte{}
+	for _, key := range []string{"header", "sidebar", "footer"} {
+		html, _ := ginH[key].(template.HTML)
+		parts = append(parts, []byte(html))
+	}
+	return build_cache.Hash(parts...)
+}
+
+// 全ページのメタデータのハッシュ
+// ナビゲーション等は他のページのタイトル、並び順に依存する
+// ページ名はjsonに含まれないため、別に加える
+func pagesDigest(pages []md_parse.MetaData) string {
+	parts := make([][]byte, 0, len(pages)*2)
+	for _, page := range pages {
+		data, err := json.Marshal(page)
+		if err != nil {
+			return ""
+		}
+		parts = append(parts, []byte(page.PageName), data)
+	}
+	return build_cache.Hash(parts...)
+}
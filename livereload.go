@@ -0,0 +1,115 @@
+package ssgen
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 再読み込みの通知を受け取るためのSSEのパス
+const reloadPath = "/__ssgen/reload"
+
+// プレビューのページへ埋め込む、再読み込みの通知を受け取るスクリプト
+const reloadScript = `<script>new EventSource("` + reloadPath + `").addEventListener("reload", function () { location.reload(); });</script>`
+
+// 開いているブラウザへ再読み込みを通知する
+type reloadHub struct {
+	mu      sync.Mutex
+	clients map[chan struct{}]struct{}
+	done    chan struct{}
+	closed  bool
+}
+
+func newReloadHub() *reloadHub {
+	return &reloadHub{
+		clients: map[chan struct{}]struct{}{},
+		done:    make(chan struct{}),
+	}
+}
+
+// 接続中の全てのブラウザへ通知する
+func (h *reloadHub) broadcast() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.clients {
+		// 未送信の通知が残っている場合はまとめる
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// 全ての接続を終了する。サーバーの停止時に接続が残らないようにするため
+func (h *reloadHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.closed {
+		h.closed = true
+		close(h.done)
+	}
+}
+
+func (h *reloadHub) subscribe() chan struct{} {
+	ch := make(chan struct{}, 1)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.clients[ch] = struct{}{}
+	return ch
+}
+
+func (h *reloadHub) unsubscribe(ch chan struct{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.clients, ch)
+}
+
+// SSEで再読み込みを通知するハンドラ
+func (h *reloadHub) handle(con *gin.Context) {
+	ch := h.subscribe()
+	defer h.unsubscribe(ch)
+
+	header := con.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	con.Status(http.StatusOK)
+	fmt.Fprint(con.Writer, ": connected\n\n")
+	con.Writer.Flush()
+
+	for {
+		select {
+		case <-con.Request.Context().Done():
+			return
+		case <-h.done:
+			return
+		case <-ch:
+			fmt.Fprint(con.Writer, "event: reload\ndata: reload\n\n")
+			con.Writer.Flush()
+		}
+	}
+}
+
+// </body>の直前(存在しない場合は末尾)へ再読み込み用のスクリプトを埋め込む
+func injectReloadScript(html []byte) []byte {
+	return injectBeforeBodyEnd(html, []byte(reloadScript))
+}
+
+// </body>の直前(存在しない場合は末尾)へsnippetを埋め込む
+func injectBeforeBodyEnd(html []byte, snippet []byte) []byte {
+	idx := bytes.LastIndex(html, []byte("</body>"))
+	if idx < 0 {
+		idx = bytes.LastIndex(html, []byte("</BODY>"))
+	}
+	if idx < 0 {
+		return append(html, snippet...)
+	}
+
+	result := make([]byte, 0, len(html)+len(snippet))
+	result = append(result, html[:idx]...)
+	result = append(result, snippet...)
+	return append(result, html[idx:]...)
+}
@@ -0,0 +1,23 @@
+package ssgen
+
+import "testing"
+
+func TestInjectBeforeBodyEnd(t *testing.T) {
+	cases := []struct {
+		html string
+		want string
+	}{
+		{html: "<html><body>a</body></html>", want: "<html><body>a<x></body></html>"},
+		{html: "<HTML><BODY>a</BODY></HTML>", want: "<HTML><BODY>a<x></BODY></HTML>"},
+		// 本文中の</body>ではなく最後のものの直前
+		{html: "<body><pre></body></pre></body>", want: "<body><pre></body></pre><x></body>"},
+		// </body>の無い場合は末尾
+		{html: "<p>a</p>", want: "<p>a</p><x>"},
+		{html: "", want: "<x>"},
+	}
+	for _, c := range cases {
+		if actual := string(injectBeforeBodyEnd([]byte(c.html), []byte("<x>"))); actual != c.want {
+			t.Errorf("Actual [%s], want [%s]", actual, c.want)
+		}
+	}
+}
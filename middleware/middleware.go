@@ -1,11 +1,13 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/TwilightUncle/ssgen/features/access_md"
 	"github.com/TwilightUncle/ssgen/features/auto_link"
 	"github.com/TwilightUncle/ssgen/features/md_parse"
+	"github.com/TwilightUncle/ssgen/features/slug"
 )
 
 type Middleware func(metaData md_parse.MetaData, bytes []byte) (md_parse.MetaData, []byte, error)
@@ -38,21 +40,86 @@ func (mList *MiddlewareList) Apply(metaData md_parse.MetaData, bytes []byte) (md
 
 // リンク作成ミドルウェアを返す
 func MakeMdAutoLink(baseUrl string, mdPathes access_md.MdPaths, suffix string) Middleware {
+	return MakeMdAutoLinkWithSlug(baseUrl, mdPathes, suffix, slug.GitHub)
+}
+
+// リンク作成ミドルウェアを返す。見出しのidはfnにより生成する
+func MakeMdAutoLinkWithSlug(baseUrl string, mdPathes access_md.MdPaths, suffix string, fn slug.Func) Middleware {
 	// 見出しデータはあらかじめ収集の上、キャプチャしておく
-	allHInfos, err := auto_link.NewMdAllHeaaderInfo(mdPathes)
-	return func(metaData md_parse.MetaData, bytes []byte) (md_parse.MetaData, []byte, error) {
-		if err != nil {
+	allHInfos, err := auto_link.NewMdAllHeaaderInfoWithSlug(mdPathes, fn)
+	if err != nil {
+		return func(metaData md_parse.MetaData, bytes []byte) (md_parse.MetaData, []byte, error) {
 			return metaData, bytes, fmt.Errorf("Failed to make middleware 'MdAutoLink': %v", err)
 		}
-		mdStr, _ := auto_link.MakeLink(baseUrl, string(bytes), allHInfos, suffix)
+	}
+	return MakeMdAutoLinkWithHeaderInfo(baseUrl, allHInfos, suffix)
+}
+
+// 収集済みの見出し情報を用いるリンク作成ミドルウェアを返す
+func MakeMdAutoLinkWithHeaderInfo(baseUrl string, allHInfos auto_link.MdAllHeaaderInfo, suffix string) Middleware {
+	return func(metaData md_parse.MetaData, bytes []byte) (md_parse.MetaData, []byte, error) {
+		// リンク先の見つからないものは、ページの問題として記録する
+		// 記述自体が不正なものはエラーとする
+		mdStr, diagnostics := auto_link.MakeLinkWithDiagnostics(baseUrl, string(bytes), allHInfos, suffix)
+		for _, d := range diagnostics {
+			if d.Invalid {
+				return metaData, bytes, metaData.LineError(d.Line, errors.New(d.Message))
+			}
+			metaData.AddWarning(d.Line, d.Message)
+		}
 		return metaData, []byte(mdStr), nil
 	}
 }
 
+// マークダウンの見出しへ{#id}の形式でidを設定するミドルウェアを返す
+// 見出しの内容を書き換える他のミドルウェアより先に実行すること
+func MakeMdHeadingIds(fn slug.Func) Middleware {
+	return func(metaData md_parse.MetaData, bytes []byte) (md_parse.MetaData, []byte, error) {
+		return metaData, []byte(auto_link.AddIdForMdH(string(bytes), fn)), nil
+	}
+}
+
 // h要素がリンクのアンカーとなるようIDを設定するミドルウェアを返す
 func MakeHtmlAutoLinker() Middleware {
+	return MakeHtmlAutoLinkerWithSlug(slug.GitHub)
+}
+
+// h要素がリンクのアンカーとなるようIDを設定するミドルウェアを返す。idはfnにより生成する
+func MakeHtmlAutoLinkerWithSlug(fn slug.Func) Middleware {
 	return func(metaData md_parse.MetaData, bytes []byte) (md_parse.MetaData, []byte, error) {
-		htmlStr := auto_link.AddIdForHtmlH(string(bytes))
+		htmlStr := auto_link.AddIdForHtmlHWithSlug(string(bytes), fn)
 		return metaData, []byte(htmlStr), nil
 	}
 }
+
+// id属性を持つh要素へ、パーマリンクを挿入するミドルウェアを返す
+// MakeHtmlAutoLinkerの後に登録すること
+func MakeHtmlHeadingAnchor(opts auto_link.AnchorOptions) Middleware {
+	return func(metaData md_parse.MetaData, bytes []byte) (md_parse.MetaData, []byte, error) {
+		htmlStr, err := auto_link.AddAnchorForHtmlH(string(bytes), opts)
+		if err != nil {
+			return metaData, bytes, fmt.Errorf("Failed to make heading anchors: %v", err)
+		}
+		return metaData, []byte(htmlStr), nil
+	}
+}
+
+// マークダウン中の[[toc]]を、ページの目次に置き換えるミドルウェアを返す
+// minDepth, maxDepthは目次に含める見出しの深さ(0の場合はそれぞれ1, 6)
+func MakeHtmlTOC(baseUrl string, mdPathes access_md.MdPaths, suffix string, fn slug.Func, minDepth int, maxDepth int) Middleware {
+	allHInfos, err := auto_link.NewMdAllHeaaderInfoWithSlug(mdPathes, fn)
+	if err != nil {
+		return func(metaData md_parse.MetaData, bytes []byte) (md_parse.MetaData, []byte, error) {
+			return metaData, bytes, fmt.Errorf("Failed to make middleware 'HtmlTOC': %v", err)
+		}
+	}
+	return MakeHtmlTOCWithHeaderInfo(baseUrl, allHInfos, suffix, minDepth, maxDepth)
+}
+
+// 収集済みの見出し情報を用いる目次のミドルウェアを返す
+func MakeHtmlTOCWithHeaderInfo(baseUrl string, allHInfos auto_link.MdAllHeaaderInfo, suffix string, minDepth int, maxDepth int) Middleware {
+	return func(metaData md_parse.MetaData, bytes []byte) (md_parse.MetaData, []byte, error) {
+		toc := auto_link.MakeTOC(baseUrl, metaData.PageName, minDepth, maxDepth, allHInfos, suffix)
+		return metaData, []byte(auto_link.ExpandTOCMarker(string(bytes), toc)), nil
+	}
+}
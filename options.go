@@ -0,0 +1,180 @@
+package ssgen
+
+import (
+	"flag"
+	"strconv"
+
+	"github.com/TwilightUncle/ssgen/features/md_parse"
+	"github.com/TwilightUncle/ssgen/features/site_config"
+	"github.com/TwilightUncle/ssgen/features/slug"
+)
+
+// Buildで実行する処理の種類
+type Mode int
+
+const (
+	// 静的サイトの構築と出力のみ
+	ModeBuild Mode = iota
+	// サーバーを起動し、動的にレンダリングを行う
+	ModePreview
+	// 静的サイトを出力したうえで、出力先を返すだけのサーバーを起動する
+	ModePreviewStatic
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModePreview:
+		return "preview"
+	case ModePreviewStatic:
+		return "preview-static"
+	}
+	return "build"
+}
+
+// New関数に渡すサイトの設定
+type Options struct {
+	BaseUrl string
+	// マークダウンを格納しているディレクトリ
+	MdBaseDir string
+	// cssやjs等の静的ファイルを格納するPATH(ディレクトリ,URL共用)
+	AssetsPath  string
+	TemplateDir string
+	OutputDir   string
+	Mode        Mode
+
+	// 空の場合は index.html
+	TemplateHtmlName string
+	// 空の場合、ModePreviewでは無し、それ以外は .html
+	UrlSuffix string
+	// テンプレートから参照可能な、サイト全体の任意の値
+	Params map[string]any
+	// プレビュー用サーバーの待ち受けアドレス。空の場合は DefaultAddr
+	Addr string
+	// 差分ビルド用のキャッシュファイル。空の場合は毎回全てを出力し直す
+	CachePath string
+	// 同時にレンダリングするページ数。0以下の場合は GOMAXPROCS
+	Concurrency int
+	// 真の場合、エラーの発生したページがあっても成功したページは出力する
+	KeepGoing bool
+	// 真の場合、警告(リンク先の見つからないリンク等)もエラーとして扱う
+	Strict bool
+	// 見出しのidを生成する関数。nilの場合はGitHubと互換のslug
+	SlugFunc slug.Func
+	// 目次(toc)に含める見出しの深さ。0の場合はそれぞれ1, 6
+	TOCMinDepth int
+	TOCMaxDepth int
+	// ページのフロントマターを検証するスキーマ
+	FrontMatterSchema md_parse.Schema
+	// 下書き等の公開されないページの扱い。既定ではModePreviewのみ含める
+	Drafts DraftsMode
+	// デフォルトの設定の後に行う任意の設定(ミドルウェアの追加等)
+	// プレビュー時の再構築でも再実行されるため、New後ではなくここで設定すること
+	Setup func(site *Site) error
+}
+
+// 設定ファイルを読み込み、記述されている項目を上書きする
+// pathが空の場合は作業ディレクトリから設定ファイルを探索し、存在しなければ何もしない
+func (o *Options) LoadConfig(path string, env string) error {
+	if path == "" {
+		found, err := site_config.Find(".")
+		if err != nil || found == "" {
+			return err
+		}
+		path = found
+	}
+
+	cfg, err := site_config.Load(path, env)
+	if err != nil {
+		return err
+	}
+	o.ApplyConfig(cfg)
+	return nil
+}
+
+// 設定の内、値が記述されている項目で上書きする
+// BaseUrlは空文字(ルート直下)も有効な値であるため、記述されていれば空文字でも上書きする
+func (o *Options) ApplyConfig(cfg site_config.Config) {
+	if cfg.BaseUrl != nil {
+		o.BaseUrl = *cfg.BaseUrl
+	}
+	overwrite(&o.MdBaseDir, cfg.MdBaseDir)
+	overwrite(&o.AssetsPath, cfg.AssetsPath)
+	overwrite(&o.TemplateDir, cfg.TemplateDir)
+	overwrite(&o.OutputDir, cfg.OutputDir)
+	overwrite(&o.TemplateHtmlName, cfg.TemplateHtmlName)
+	overwrite(&o.UrlSuffix, cfg.UrlSuffix)
+	overwrite(&o.CachePath, cfg.CachePath)
+	if cfg.Params != nil {
+		o.Params = cfg.Params
+	}
+	if cfg.FrontMatter != nil {
+		o.FrontMatterSchema = *cfg.FrontMatter
+	}
+}
+
+func overwrite(dest *string, src string) {
+	if src != "" {
+		*dest = src
+	}
+}
+
+// 任意のFlagSetへオプションを紐づける
+// 解析(fs.Parse)は呼び出し側で行うこと
+func (o *Options) BindFlags(fs *flag.FlagSet) {
+	o.BindSiteFlags(fs)
+	bindModeFlags(fs, &o.Mode)
+}
+
+// 実行モード以外のオプションのみ、任意のFlagSetへ紐づける
+func (o *Options) BindSiteFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.BaseUrl, "base-url", o.BaseUrl, "base url of the site")
+	fs.StringVar(&o.MdBaseDir, "md-dir", o.MdBaseDir, "directory containing markdown files")
+	fs.StringVar(&o.AssetsPath, "assets", o.AssetsPath, "directory (and url path) of static assets")
+	fs.StringVar(&o.TemplateDir, "templates", o.TemplateDir, "directory containing html templates")
+	fs.StringVar(&o.OutputDir, "output", o.OutputDir, "output directory of the static site")
+	fs.StringVar(&o.CachePath, "cache", o.CachePath, "cache file for incremental builds (empty to rebuild everything)")
+	fs.IntVar(&o.Concurrency, "concurrency", o.Concurrency, "number of pages rendered in parallel (default GOMAXPROCS)")
+	fs.BoolVar(&o.KeepGoing, "keep-going", o.KeepGoing, "write the pages that succeeded even if some pages fail")
+	fs.BoolVar(&o.Strict, "strict", o.Strict, "treat warnings such as unresolved links as errors")
+	fs.Var(draftsFlag{target: &o.Drafts}, "drafts", "include drafts, scheduled and expired pages (default: only in the preview server)")
+	fs.StringVar(&o.Addr, "addr", o.Addr, "listen address of the preview server (default "+DefaultAddr+")")
+}
+
+// Default, Initializeで初期化するサイトの実行モードを任意のFlagSetへ紐づける
+// Default, Initializeより前に呼び出し、解析を済ませておくこと
+func BindFlags(fs *flag.FlagSet) {
+	bindModeFlags(fs, &defaultSite.Mode)
+}
+
+// -preview, -preview-static を登録する。後に指定されたものが優先
+func bindModeFlags(fs *flag.FlagSet, target *Mode) {
+	fs.Var(modeFlag{target: target, mode: ModePreview}, "preview", "run preview server")
+	fs.Var(modeFlag{target: target, mode: ModePreviewStatic}, "preview-static", "run preview static")
+}
+
+// 真偽値フラグとして振る舞い、真が指定された場合に対象のモードを設定する
+type modeFlag struct {
+	target *Mode
+	mode   Mode
+}
+
+func (f modeFlag) IsBoolFlag() bool {
+	return true
+}
+
+func (f modeFlag) String() string {
+	return strconv.FormatBool(f.target != nil && *f.target == f.mode)
+}
+
+func (f modeFlag) Set(value string) error {
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	if enabled {
+		*f.target = f.mode
+	} else if *f.target == f.mode {
+		*f.target = ModeBuild
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package ssgen
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBindFlags(t *testing.T) {
+	cases := []struct {
+		args []string
+		want Mode
+	}{
+		{args: []string{}, want: ModeBuild},
+		{args: []string{"-preview"}, want: ModePreview},
+		{args: []string{"-preview-static=true"}, want: ModePreviewStatic},
+		// 後に指定されたものが優先
+		{args: []string{"-preview", "-preview-static"}, want: ModePreviewStatic},
+		{args: []string{"-preview-static", "-preview"}, want: ModePreview},
+		// 偽の指定は、そのモードである場合のみ解除する
+		{args: []string{"-preview", "-preview=false"}, want: ModeBuild},
+		{args: []string{"-preview-static", "-preview=false"}, want: ModePreviewStatic},
+	}
+	for _, c := range cases {
+		var opts Options
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		opts.BindFlags(fs)
+		if err := fs.Parse(c.args); err != nil {
+			t.Errorf("%v: %v", c.args, err)
+			continue
+		}
+		if opts.Mode != c.want {
+			t.Errorf("%v: Actual [%s], want [%s]", c.args, opts.Mode, c.want)
+		}
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var opts Options
+	opts.BindFlags(fs)
+	if err := fs.Parse([]string{"-preview=maybe"}); err == nil {
+		t.Error("want error for invalid bool")
+	}
+}
+
+func TestBindFlagsDefaultSite(t *testing.T) {
+	t.Cleanup(func() { defaultSite.Mode = ModeBuild })
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	BindFlags(fs)
+	if err := fs.Parse([]string{"-preview-static"}); err != nil {
+		t.Fatal(err)
+	}
+	if defaultSite.Mode != ModePreviewStatic {
+		t.Errorf("Actual [%s], want [%s]", defaultSite.Mode, ModePreviewStatic)
+	}
+}
+
+func TestLoadConfigBaseUrl(t *testing.T) {
+	dir := t.TempDir()
+	cases := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		// 記述の無い場合は呼び出し側の値を維持する
+		{name: "absent", config: "params:\n  siteName: test\n", want: "/docs"},
+		// 空文字も有効な値として上書きする
+		{name: "empty", config: "baseUrl: \"\"\n", want: ""},
+		{name: "set", config: "baseUrl: https://example.com\n", want: "https://example.com"},
+	}
+	for _, c := range cases {
+		path := filepath.Join(dir, c.name+".yaml")
+		if err := os.WriteFile(path, []byte(c.config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		opts := Options{BaseUrl: "/docs"}
+		if err := opts.LoadConfig(path, ""); err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if opts.BaseUrl != c.want {
+			t.Errorf("%s: Actual [%s], want [%s]", c.name, opts.BaseUrl, c.want)
+		}
+	}
+}
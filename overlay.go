@@ -0,0 +1,134 @@
+package ssgen
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"os"
+	"strings"
+)
+
+// エラー箇所の前後に表示する行数
+const overlayContextLines = 3
+
+// プレビューでビルドエラーを表示するページ
+var errorOverlayTemplate = template.Must(template.New("overlay").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>Build error - ssgen</title>
+  <style>
+    body { margin: 0; padding: 2em; background: #1e1e1e; color: #eee; font-family: sans-serif; }
+    h1 { color: #ff6b6b; font-size: 1.4em; }
+    dt { color: #aaa; }
+    dd { margin: 0 0 .5em 0; font-family: monospace; }
+    pre { background: #2d2d2d; padding: 1em; overflow-x: auto; white-space: pre-wrap; }
+    .error-line { background: #5c2b2b; display: block; }
+    .note { color: #aaa; font-size: .9em; }
+  </style>
+</head>
+<body>
+  <h1>Build error</h1>
+  <dl>
+    <dt>file</dt>
+    <dd>{{.Path}}{{if .Line}}:{{.Line}}{{end}}</dd>
+    {{- if and .Page (ne .Page .Path)}}
+    <dt>page</dt>
+    <dd>{{.Page}}</dd>
+    {{- end}}
+    <dt>stage</dt>
+    <dd>{{.Stage}}</dd>
+  </dl>
+  <pre>{{.Message}}</pre>
+  {{- if .Source}}
+  <pre>{{range .Source}}<span{{if .Current}} class="error-line"{{end}}>{{printf "%4d" .Number}} | {{.Text}}</span>
+{{end}}</pre>
+  {{- end}}
+  <p class="note">This page reloads automatically once the source is fixed.</p>
+</body>
+</html>
+`))
+
+// プレビューのページ上に警告を表示する領域
+var warningBannerTemplate = template.Must(template.New("warnings").Parse(`<div id="ssgen-warnings" style="position: fixed; right: 1em; bottom: 1em; max-width: 40em; max-height: 40vh; overflow: auto; z-index: 2147483647; padding: .5em 1em; background: #fff8e1; color: #5d4037; border: 1px solid #ffb300; font: 13px/1.5 monospace;">
+  <strong>ssgen: {{len .}} warning(s)</strong>
+  <ul style="margin: .3em 0; padding-left: 1.2em;">
+    {{- range .}}
+    <li>{{.Error}}</li>
+    {{- end}}
+  </ul>
+</div>`))
+
+// プレビューのページ上に、公開されないページであることを表示する領域
+var draftBannerTemplate = template.Must(template.New("draft").Parse(`<div id="ssgen-draft" style="position: fixed; left: 1em; top: 1em; z-index: 2147483647; padding: .3em .8em; background: #e53935; color: #fff; font: bold 13px/1.5 sans-serif; border-radius: 3px;">
+  DRAFT: {{.}} (not included in the build)
+</div>`))
+
+// 公開されないページであることを表示するHTMLを生成する
+func renderDraftBanner(reason string) []byte {
+	var buf bytes.Buffer
+	if err := draftBannerTemplate.Execute(&buf, reason); err != nil {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// 警告を表示するHTMLを生成する
+func renderWarningBanner(warnings []*BuildError) []byte {
+	var buf bytes.Buffer
+	if err := warningBannerTemplate.Execute(&buf, warnings); err != nil {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// エラー箇所周辺のソースの1行
+type sourceLine struct {
+	Number  int
+	Text    string
+	Current bool
+}
+
+// エラー内容を表示するHTMLを生成する
+func renderErrorOverlay(err error) []byte {
+	var buildErr *BuildError
+	if !errors.As(err, &buildErr) {
+		buildErr = &BuildError{Stage: StageSetup, Err: err}
+	}
+
+	data := map[string]any{
+		"Path":    buildErr.Path,
+		"Page":    buildErr.Page,
+		"Stage":   buildErr.Stage,
+		"Line":    buildErr.Line,
+		"Message": buildErr.Err.Error(),
+		"Source":  readSourceLines(buildErr.Path, buildErr.Line),
+	}
+
+	var buf bytes.Buffer
+	if execErr := errorOverlayTemplate.Execute(&buf, data); execErr != nil {
+		return []byte(template.HTMLEscapeString(err.Error()))
+	}
+	return buf.Bytes()
+}
+
+// エラー行の前後のソースを取得する。取得できない場合はnil
+func readSourceLines(path string, line int) []sourceLine {
+	if path == "" || line <= 0 {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	result := []sourceLine{}
+	for i := line - overlayContextLines; i <= line+overlayContextLines; i++ {
+		if i < 1 || i > len(lines) {
+			continue
+		}
+		result = append(result, sourceLine{Number: i, Text: lines[i-1], Current: i == line})
+	}
+	return result
+}
@@ -0,0 +1,48 @@
+package ssgen
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadSourceLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "page.md")
+	writeTestFile(t, path, "1\r\n2\r\n3\r\n4\r\n5\r\n6\r\n")
+
+	want := []sourceLine{
+		{Number: 1, Text: "1"},
+		{Number: 2, Text: "2", Current: true},
+		{Number: 3, Text: "3"},
+		{Number: 4, Text: "4"},
+		{Number: 5, Text: "5"},
+	}
+	if actual := readSourceLines(path, 2); !reflect.DeepEqual(actual, want) {
+		t.Errorf("Actual [%+v], want [%+v]", actual, want)
+	}
+	if actual := readSourceLines(path, 0); actual != nil {
+		t.Errorf("Actual [%+v], want nil", actual)
+	}
+}
+
+func TestRenderErrorOverlay(t *testing.T) {
+	// BuildError以外のエラーは設定処理のエラーとして表示する
+	overlay := string(renderErrorOverlay(errors.New("<broken>")))
+	for _, want := range []string{"<dd>setup</dd>", "<pre>&lt;broken&gt;</pre>"} {
+		if !strings.Contains(overlay, want) {
+			t.Errorf("Actual [%s], want %s", overlay, want)
+		}
+	}
+
+	// テンプレートのエラーは変換中のページも表示する
+	err := fmt.Errorf("build: %w", &BuildError{Path: "index.html", Page: "md/page.md", Stage: StageTemplate, Line: 4, Err: errors.New("fail")})
+	overlay = string(renderErrorOverlay(err))
+	for _, want := range []string{"<dd>index.html:4</dd>", "<dd>md/page.md</dd>", "<dd>template</dd>"} {
+		if !strings.Contains(overlay, want) {
+			t.Errorf("Actual [%s], want %s", overlay, want)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package ssgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TwilightUncle/ssgen/features/md_parse"
+	"github.com/TwilightUncle/ssgen/features/watch"
+
+	"github.com/gin-gonic/gin"
+)
+
+// プレビュー用サーバーの待ち受けアドレスの既定値
+const DefaultAddr = ":8080"
+
+// 停止時に処理中のリクエストの完了を待つ時間
+const shutdownTimeout = 5 * time.Second
+
+// プレビュー時にファイルの変更を確認する間隔
+const watchInterval = 500 * time.Millisecond
+
+// 静的ファイル生成の上、プレビュー
+func (s *Site) RunPreviewStatic() error {
+	return s.ServePreviewStatic(context.Background())
+}
+
+// プレビュー用サーバー起動
+func (s *Site) RunPreviewServer() error {
+	return s.ServePreview(context.Background())
+}
+
+// モードに応じたプレビュー用サーバーを起動し、ctxの終了により停止する
+func (s *Site) Serve(ctx context.Context) error {
+	if s.Mode == ModePreviewStatic {
+		return s.ServePreviewStatic(ctx)
+	}
+	return s.ServePreview(ctx)
+}
+
+// 静的ファイル生成の上、出力先を返すサーバーを起動する
+func (s *Site) ServePreviewStatic(ctx context.Context) error {
+	if err := s.BuildStaticSite(); err != nil {
+		return err
+	}
+	return s.listenAndServe(ctx, s.StaticHandler())
+}
+
+// プレビュー用サーバーを起動する
+func (s *Site) ServePreview(ctx context.Context) error {
+	handler, err := s.PreviewHandler(ctx)
+	if err != nil {
+		return err
+	}
+	return s.listenAndServe(ctx, handler)
+}
+
+// 出力先ディレクトリのファイルを返すハンドラ
+func (s *Site) StaticHandler() http.Handler {
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.Default()
+	router.Static("/", s.OutputDir)
+	return router
+}
+
+// マークダウンを動的にレンダリングするハンドラ
+// ctxが終了するまで、ファイルの変更を監視してサイトを再構築し、開いているブラウザを再読み込みさせる
+func (s *Site) PreviewHandler(ctx context.Context) (http.Handler, error) {
+	if !s.initialized {
+		return nil, fmt.Errorf("Prease Call the function 'Default' or 'Initialize' beforehand.")
+	}
+
+	p := &previewServer{hub: newReloadHub()}
+	p.update(s)
+
+	w, err := watch.New(s.watchPaths(), watchInterval)
+	if err != nil {
+		return nil, err
+	}
+	go w.Run(ctx, p.onChange, func(err error) {
+		fmt.Fprintln(s.logWriter(), err)
+	})
+	go func() {
+		<-ctx.Done()
+		p.hub.close()
+	}()
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.Default()
+	router.Static("/"+s.AssetsPath, s.AssetsPath)
+	router.GET(reloadPath, p.hub.handle)
+	// マークダウンのファイル配置は変化するため、routeは固定せずリクエスト時に解決する
+	router.NoRoute(p.handlePage)
+	return router, nil
+}
+
+// 変更を監視するディレクトリ
+func (s *Site) watchPaths() []string {
+	paths := []string{s.MdPaths.GetBaseDirPath(), s.TemplateDir, s.AssetsPath}
+	if s.layoutDir != "" {
+		paths = append(paths, s.layoutDir)
+	}
+	return paths
+}
+
+// s.Addrで待ち受け、ctxの終了により停止する
+func (s *Site) listenAndServe(ctx context.Context, handler http.Handler) error {
+	addr := s.Addr
+	if addr == "" {
+		addr = DefaultAddr
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return ServeListener(ctx, ln, handler)
+}
+
+// 任意のリスナーでハンドラを提供し、ctxの終了により処理中のリクエストを待ってから停止する
+func ServeListener(ctx context.Context, ln net.Listener, handler http.Handler) error {
+	server := &http.Server{Handler: handler}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Serve(ln)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// 動的プレビューの状態。ファイルの変更により再構築される
+type previewServer struct {
+	mu      sync.Mutex
+	site    *Site
+	tmpl    *template.Template
+	tmplErr error
+	// リクエストのパス(先頭の/を除く)をキーにしたマークダウンのパス
+	pages map[string]string
+	// 直近の再構築の失敗。修正されるまで全ページでエラーを表示する
+	rebuildErr error
+	hub        *reloadHub
+}
+
+// 表示するサイトを差し替える
+func (p *previewServer) update(site *Site) {
+	pages := map[string]string{}
+	for _, mdPath := range site.MdPaths.GetAll() {
+		pages[filepath.ToSlash(site.MdPaths.GetPageName(mdPath))] = mdPath
+	}
+
+	tmpl, tmplErr := site.loadTemplate()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.site = site
+	p.tmpl = tmpl
+	p.tmplErr = tmplErr
+	p.pages = pages
+	p.rebuildErr = nil
+}
+
+// ファイルの変更時にサイトを再構築し、ブラウザへ再読み込みを通知する
+func (p *previewServer) onChange(changed []string) {
+	p.mu.Lock()
+	current := p.site
+	p.mu.Unlock()
+
+	next, err := current.rebuild()
+	if err != nil {
+		// 再構築に失敗した場合はページの対応は直前の状態を維持し、エラーを表示する
+		fmt.Fprintln(current.logWriter(), err)
+		p.mu.Lock()
+		p.rebuildErr = err
+		p.mu.Unlock()
+	} else {
+		p.update(next)
+	}
+	p.hub.broadcast()
+}
+
+// リクエストのパスに該当するページをレンダリングして返す
+func (p *previewServer) handlePage(con *gin.Context) {
+	if con.Request.Method != http.MethodGet && con.Request.Method != http.MethodHead {
+		con.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pagename := strings.TrimPrefix(con.Request.URL.Path, "/")
+	if pagename == "" {
+		pagename = "index"
+	}
+	if p.site.UrlSuffix != "" {
+		pagename = strings.TrimSuffix(pagename, p.site.UrlSuffix)
+	}
+	mdPath, ok := p.pages[pagename]
+	if !ok && p.rebuildErr == nil {
+		con.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	err := p.rebuildErr
+	if err == nil {
+		err = p.tmplErr
+	}
+	var htmlBytes []byte
+	var warnings []md_parse.Warning
+	if err == nil {
+		htmlBytes, warnings, err = p.site.renderPage(p.tmpl, mdPath)
+	}
+	if err != nil {
+		fmt.Fprintln(p.site.logWriter(), err)
+		// 修正後はファイルの変更の通知により自動で再読み込みされる
+		con.Data(http.StatusInternalServerError, "text/html; charset=utf-8", injectReloadScript(renderErrorOverlay(err)))
+		return
+	}
+	// リンク先の見つからないリンク等はページ上に表示する
+	report := p.site.newBuildReport()
+	report.addWarnings(mdPath, warnings, false)
+	if len(report.Errors) > 0 || len(report.Warnings) > 0 {
+		htmlBytes = injectBeforeBodyEnd(htmlBytes, renderWarningBanner(append(report.Errors, report.Warnings...)))
+	}
+	// 公開されないページはその旨を表示する
+	if reason, ok := p.site.unpublished[mdPath]; ok {
+		htmlBytes = injectBeforeBodyEnd(htmlBytes, renderDraftBanner(reason))
+	}
+	con.Data(http.StatusOK, "text/html; charset=utf-8", injectReloadScript(htmlBytes))
+}
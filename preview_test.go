@@ -0,0 +1,195 @@
+package ssgen
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TwilightUncle/ssgen/helpers/testing_helper"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 監視を行わず、onChangeを直接呼び出せるプレビューを生成する
+func newTestPreview(t *testing.T, opts Options) (*previewServer, http.Handler) {
+	opts.Mode = ModePreview
+	site, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	site.Log = io.Discard
+	p := &previewServer{hub: newReloadHub()}
+	p.update(site)
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.NoRoute(p.handlePage)
+	return p, router
+}
+
+func getPreview(handler http.Handler, path string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	return recorder
+}
+
+func TestPreviewRebuild(t *testing.T) {
+	opts := makeTestSite(t, map[string]string{"index.md": "# Home\n"})
+	p, handler := newTestPreview(t, opts)
+
+	res := getPreview(handler, "/")
+	if res.Code != http.StatusOK || !strings.Contains(res.Body.String(), reloadScript+"</body>") {
+		t.Errorf("Actual [%d %s], want page with reload script", res.Code, res.Body.String())
+	}
+	if res := getPreview(handler, "/added"); res.Code != http.StatusNotFound {
+		t.Errorf("Actual [%d], want 404", res.Code)
+	}
+
+	// 追加されたページは再構築により表示し、ブラウザへ通知する
+	reload := p.hub.subscribe()
+	added := filepath.Join(opts.MdBaseDir, "added.md")
+	writeTestFile(t, added, "# Added\n")
+	p.onChange([]string{added})
+	if res := getPreview(handler, "/added"); res.Code != http.StatusOK || !strings.Contains(res.Body.String(), "Added") {
+		t.Errorf("Actual [%d %s], want 200", res.Code, res.Body.String())
+	}
+	select {
+	case <-reload:
+	default:
+		t.Error("want reload notification")
+	}
+}
+
+func TestPreviewPageError(t *testing.T) {
+	opts := makeTestSite(t, map[string]string{
+		"index.md": "# Home\n",
+		"bad.md":   "---\ntitle: Bad\nweight: [\n---\n# Bad\n",
+	})
+	p, handler := newTestPreview(t, opts)
+	badPath := filepath.Join(opts.MdBaseDir, "bad.md")
+	var log bytes.Buffer
+	p.site.Log = &log
+
+	// エラー箇所のファイル、段階、行を表示する
+	res := getPreview(handler, "/bad")
+	body := res.Body.String()
+	for _, want := range []string{
+		"<dd>" + badPath + ":3</dd>",
+		"<dd>front-matter</dd>",
+		`<span class="error-line">   3 | weight: [</span>`,
+		reloadScript,
+	} {
+		if res.Code != http.StatusInternalServerError || !strings.Contains(body, want) {
+			t.Errorf("Actual [%d %s], want 500 with %s", res.Code, body, want)
+		}
+	}
+	if !strings.Contains(log.String(), badPath+":3") {
+		t.Errorf("Actual [%s], want the page error in the log", log.String())
+	}
+	// 他のページは表示できる
+	if res := getPreview(handler, "/"); res.Code != http.StatusOK {
+		t.Errorf("Actual [%d %s], want 200", res.Code, res.Body.String())
+	}
+
+	// 修正後は再構築によりページを表示する
+	writeTestFile(t, badPath, "---\ntitle: Bad\nweight: 1\n---\n# Bad\n")
+	p.onChange([]string{badPath})
+	if res := getPreview(handler, "/bad"); res.Code != http.StatusOK {
+		t.Errorf("Actual [%d %s], want 200", res.Code, res.Body.String())
+	}
+}
+
+func TestPreviewRebuildError(t *testing.T) {
+	opts := makeTestSite(t, map[string]string{"index.md": "# Home\n"})
+	p, handler := newTestPreview(t, opts)
+	layoutDir := filepath.Join(opts.MdBaseDir, "layout")
+	var log bytes.Buffer
+	p.site.Log = &log
+
+	// 再構築に失敗した場合は全てのページでエラーを表示し、サイトの出力先へも出力する
+	if err := os.RemoveAll(layoutDir); err != nil {
+		t.Fatal(err)
+	}
+	p.onChange([]string{layoutDir})
+	res := getPreview(handler, "/")
+	if res.Code != http.StatusInternalServerError || !strings.Contains(res.Body.String(), layoutDir) {
+		t.Errorf("Actual [%d %s], want 500 with the layout file", res.Code, res.Body.String())
+	}
+	if !strings.Contains(log.String(), layoutDir) {
+		t.Errorf("Actual [%s], want the rebuild error in the log", log.String())
+	}
+
+	testing_helper.MakeTestFiles(layoutDir, []testing_helper.TestFileData{
+		{Path: filepath.Join(layoutDir, "_header.md"), Contents: []byte("# header\n")},
+		{Path: filepath.Join(layoutDir, "_sidebar.md"), Contents: []byte("sidebar\n")},
+		{Path: filepath.Join(layoutDir, "_footer.md"), Contents: []byte("footer\n")},
+	}, t)
+	p.onChange([]string{layoutDir})
+	if res := getPreview(handler, "/"); res.Code != http.StatusOK {
+		t.Errorf("Actual [%d %s], want 200", res.Code, res.Body.String())
+	}
+	// 再構築したサイトも同じ出力先を用いる
+	if p.site.Log != &log {
+		t.Error("want the log writer kept by the rebuild")
+	}
+}
+
+// 待ち受けを開始し、ctxの終了によるServeListenerの結果を返すチャネルとアドレスを返す
+func serveTestListener(t *testing.T, ctx context.Context, handler http.Handler) (<-chan error, string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- ServeListener(ctx, ln, handler)
+	}()
+	return errCh, "http://" + ln.Addr().String()
+}
+
+func TestServeListener(t *testing.T) {
+	opts := makeTestSite(t, map[string]string{"index.md": "# Home\n"})
+	opts.Mode = ModePreview
+	site, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	handler, err := site.PreviewHandler(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errCh, url := serveTestListener(t, ctx, handler)
+
+	// 再読み込みの通知を待つ接続が残っていても停止する
+	res, err := http.Get(url + reloadPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	line, err := bufio.NewReader(res.Body).ReadString('\n')
+	if err != nil || line != ": connected\n" {
+		t.Fatalf("Actual [%q %v], want connected", line, err)
+	}
+
+	// 停止した場合はnilを返す
+	cancel()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Errorf("Actual [%v], want nil", err)
+		}
+	case <-time.After(shutdownTimeout):
+		t.Error("server did not stop")
+	}
+}
@@ -2,19 +2,25 @@ package ssgen
 
 import (
 	"bytes"
-	"flag"
+	"context"
 	"fmt"
 	"html/template"
 	"io"
-	"net/http"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/TwilightUncle/ssgen/features/access_md"
 	"github.com/TwilightUncle/ssgen/features/auto_link"
+	"github.com/TwilightUncle/ssgen/features/build_cache"
+	"github.com/TwilightUncle/ssgen/features/link_check"
 	"github.com/TwilightUncle/ssgen/features/md_parse"
+	"github.com/TwilightUncle/ssgen/features/slug"
 	"github.com/TwilightUncle/ssgen/middleware"
 
 	"github.com/gin-gonic/gin"
@@ -23,7 +29,9 @@ import (
 
 type LayoutBuilder func(metaData md_parse.MetaData, convertedHtml template.HTML) gin.H
 
-type Core struct {
+// 一つのサイトの生成に必要な設定と状態を保持する
+// New関数、もしくはDefault、Initialize関数経由で生成すること
+type Site struct {
 	// htmlへ返還前のマークダウンに対して行う処理リスト
 	MdMiddlewareList middleware.MiddlewareList
 	// 変換後のhtmlに対して行う処理リスト
@@ -37,109 +45,297 @@ type Core struct {
 	TemplateHtmlName string
 	OutputDir        string
 	UrlSuffix        string
-
+	// 差分ビルド用のキャッシュファイル。空の場合は毎回全てを出力し直す
+	CachePath string
+	// 同時にレンダリングするページ数。0以下の場合は GOMAXPROCS
+	Concurrency int
+	// 真の場合、エラーの発生したページがあっても成功したページは出力する
+	KeepGoing bool
+	// 真の場合、警告(リンク先の見つからないリンク等)もエラーとして扱う
+	Strict bool
+	// ビルドの警告、プレビュー時のエラーの出力先。nilの場合は標準エラー出力
+	Log io.Writer
+	// プレビュー用サーバーの待ち受けアドレス
+	Addr string
+	// テンプレートから params として参照可能な、サイト全体の任意の値
+	Params map[string]any
+	// 見出しのidを生成する関数
+	SlugFunc slug.Func
+	// 目次(toc)に含める見出しの深さ。0の場合はそれぞれ1, 6
+	TOCMinDepth int
+	TOCMaxDepth int
+	// ページのフロントマターを検証するスキーマ
+	FrontMatterSchema md_parse.Schema
+	// 下書き等の公開されないページの扱い
+	Drafts DraftsMode
+
+	// Buildで実行する処理
+	Mode Mode
+
+	// New, Initializeで指定された設定処理。プレビュー時の再構築に用いる
+	setup func(site *Site) error
+	// MakeDefaultLayoutBuilderで指定されたレイアウト部品のディレクトリ
+	layoutDir string
+	// MakeDefaultLayoutBuilderで変換したレイアウト部品のハッシュ。差分ビルドのキーに用いる
+	layoutDigest string
+	// MakeDefaultLayoutBuilderで収集した全ページのメタデータのハッシュ。差分ビルドのキーに用いる
+	pagesDigest string
+	// MakeDefaultLayoutBuilderで変換したレイアウト部品の警告
+	layoutWarnings *BuildReport
+	// 公開されないページのマークダウンのパスと、その理由
+	unpublished map[string]string
+	// Default, Initializeで生成したサイト(プレビュー時の再構築を含む)
+	legacy      bool
 	initialized bool
-	previewFlag int
 }
 
-var c Core
+// 旧来の名称。Siteと同一
+type Core = Site
 
-const (
-	buildOnly = iota
-	preview
-	previewStatic
-)
+// Default, Initialize及びパッケージ関数が操作するサイト
+var defaultSite = &Site{}
 
-// デフォルトの設定。引数にはマークダウンを格納しているディレクトリと、cssやjs等の静的ファイルを格納するPATH(ディレクトリ,URL共用)を指定
-func Default(baseUrl string, mdBaseDir string, assetsPath string, templateDir string, outputDir string) error {
-	return Initialize(func(core *Core) error {
-		suffix := ""
-		if c.previewFlag != preview {
-			suffix = ".html"
+// Initializeのfnを実行中のサイト
+// プレビュー時の再構築ではdefaultSiteとは別のサイトに対してfnを再実行するため、
+// fn内で呼ばれたパッケージ関数はdefaultSiteではなくこのサイトを対象とする
+var setupSite atomic.Pointer[Site]
+
+// パッケージ関数が対象とするサイト。Initializeのfnの実行中であればそのサイト
+func currentSite() *Site {
+	if s := setupSite.Load(); s != nil {
+		return s
+	}
+	return defaultSite
+}
+
+// 設定処理を実行し、初期化済みとする
+// Newで生成したサイトは他のサイトと独立して設定するため、排他制御を行わない
+// Initializeのfnは一つずつ実行し、実行中に再度呼ばれた場合は待たずにエラーとする
+func (s *Site) init() error {
+	if s.legacy {
+		if !setupSite.CompareAndSwap(nil, s) {
+			return fmt.Errorf("cannot set up the site while another site is being set up by 'Initialize'")
 		}
+		defer setupSite.Store(nil)
+	}
+	if err := s.setup(s); err != nil {
+		return err
+	}
+	s.initialized = true
+	return nil
+}
 
-		var err error
-		mdLayoutDir := mdBaseDir + "/layout"
-		// マークダウンパス取得
-		core.MdPaths, err = access_md.NewMdPaths(
-			mdBaseDir,
-			[]string{mdLayoutDir},
-			[]string{".md"},
-		)
-		if err != nil {
+// デフォルトの設定で独立したサイトを生成する
+func New(opts Options) (*Site, error) {
+	return newSite(opts.Mode, func(site *Site) error {
+		if err := site.setupDefault(opts); err != nil {
 			return err
 		}
+		if opts.Setup != nil {
+			return opts.Setup(site)
+		}
+		return nil
+	})
+}
 
-		// ミドルウェア登録
-		core.MdMiddlewareList.Append(middleware.MakeMdAutoLink(baseUrl, c.MdPaths, suffix))
-		core.HtmlMiddlewareList.Append(middleware.MakeHtmlAutoLinker())
-
-		// その他
-		core.BaseUrl = baseUrl
-		core.AssetsPath = assetsPath
-		core.TemplateDir = templateDir
-		core.TemplateHtmlName = "index.html"
-		core.OutputDir = outputDir
-		core.UrlSuffix = suffix
-		core.LayoutBuilder, err = MakeDefaultLayoutBuilder(baseUrl, assetsPath, mdLayoutDir)
-		return err
+// 設定処理を実行してサイトを生成する
+func newSite(mode Mode, setup func(site *Site) error) (*Site, error) {
+	s := &Site{Mode: mode, setup: setup}
+	if err := s.init(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// 同じ設定処理により、現在のファイル配置から新たにサイトを生成する
+func (s *Site) rebuild() (*Site, error) {
+	if s.setup == nil {
+		return nil, fmt.Errorf("cannot rebuild the site not created by 'New', 'Default' or 'Initialize'")
+	}
+	// 出力先は設定処理の外で指定されるため引き継ぐ
+	next := &Site{Mode: s.Mode, setup: s.setup, legacy: s.legacy, Log: s.Log}
+	if err := next.init(); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+// デフォルトの設定。引数にはマークダウンを格納しているディレクトリと、cssやjs等の静的ファイルを格納するPATH(ディレクトリ,URL共用)を指定
+func Default(baseUrl string, mdBaseDir string, assetsPath string, templateDir string, outputDir string) error {
+	return Initialize(func(core *Core) error {
+		return core.setupDefault(Options{
+			BaseUrl:     baseUrl,
+			MdBaseDir:   mdBaseDir,
+			AssetsPath:  assetsPath,
+			TemplateDir: templateDir,
+			OutputDir:   outputDir,
+		})
 	})
 }
 
 // 任意の処理による初期化
+// 実行モードはBindFlagsでフラグから、もしくはfn内でcore.Modeへ設定する
+// fnはプレビュー時のファイル変更により、新たなサイトに対して再実行される
+// その際、fn内で呼ばれたMakeDefaultLayoutBuilder等のパッケージ関数も新たなサイトを対象とする
+// fn内でNewにより別のサイトを生成できるが、Default, Initializeは呼び出せない
 func Initialize(fn func(core *Core) error) error {
 	// 初期化済みの場合エラー
-	if c.initialized {
+	if defaultSite.initialized {
 		return fmt.Errorf("already initialized. cannot be call")
 	}
+	// 設定中のfnを上書きしないよう、実行中であれば何もせずエラー
+	if setupSite.Load() != nil {
+		return fmt.Errorf("cannot call 'Initialize' while setting up the site")
+	}
 
-	previewFlag := flag.Bool("preview", false, "run preview server")
-	previewStaticFlag := flag.Bool("preview-static", false, "run preview static")
-	flag.Parse()
+	defaultSite.setup = fn
+	defaultSite.legacy = true
+	return defaultSite.init()
+}
 
-	switch {
-	case *previewStaticFlag:
-		c.previewFlag = previewStatic
-	case *previewFlag:
-		c.previewFlag = preview
-	default:
-		c.previewFlag = buildOnly
+// オプションに従い、マークダウンのパス収集、ミドルウェア登録等を行う
+func (s *Site) setupDefault(opts Options) error {
+	suffix := opts.UrlSuffix
+	if suffix == "" && s.Mode != ModePreview {
+		suffix = ".html"
+	}
+	templateHtmlName := opts.TemplateHtmlName
+	if templateHtmlName == "" {
+		templateHtmlName = "index.html"
 	}
 
-	if err := fn(&c); err != nil {
+	var err error
+	mdLayoutDir := opts.MdBaseDir + "/layout"
+	// マークダウンパス取得
+	s.MdPaths, err = access_md.NewMdPaths(
+		opts.MdBaseDir,
+		[]string{mdLayoutDir},
+		[]string{".md"},
+	)
+	if err != nil {
 		return err
 	}
+	// 見出し、メタデータは一度だけ収集し、リンク、目次、レイアウトで共有する
+	s.SlugFunc = opts.SlugFunc
+	allHInfos, err := auto_link.NewMdAllHeaaderInfoWithSlug(s.MdPaths, s.slugFunc())
+	if err != nil {
+		return err
+	}
+	// 公開されないページは出力、リンク、ナビゲーション等の全てから除外する
+	s.Drafts = opts.Drafts
+	s.unpublished = unpublishedPages(s.MdPaths, allHInfos, time.Now())
+	if !s.includeDrafts() {
+		unpublishedNames := map[string]bool{}
+		for mdPath := range s.unpublished {
+			unpublishedNames[filepath.ToSlash(s.MdPaths.GetPageName(mdPath))] = true
+		}
+		s.MdPaths = s.MdPaths.Filter(func(mdPath string) bool {
+			_, ok := s.unpublished[mdPath]
+			return !ok
+		})
+		allHInfos = allHInfos.Filter(func(pagename string) bool {
+			return !unpublishedNames[pagename]
+		})
+	}
 
-	c.initialized = true
-	return nil
+	s.TOCMinDepth = opts.TOCMinDepth
+	s.TOCMaxDepth = opts.TOCMaxDepth
+	if err := opts.FrontMatterSchema.Check(); err != nil {
+		return err
+	}
+	s.FrontMatterSchema = opts.FrontMatterSchema
+
+	// ミドルウェア登録
+	s.MdMiddlewareList.Append(
+		middleware.MakeMdHeadingIds(s.slugFunc()),
+		middleware.MakeMdAutoLinkWithHeaderInfo(opts.BaseUrl, allHInfos, suffix),
+	)
+	s.HtmlMiddlewareList.Append(
+		middleware.MakeHtmlAutoLinkerWithSlug(s.slugFunc()),
+		middleware.MakeHtmlTOCWithHeaderInfo(opts.BaseUrl, allHInfos, suffix, s.TOCMinDepth, s.TOCMaxDepth),
+	)
+
+	// その他
+	s.BaseUrl = opts.BaseUrl
+	s.AssetsPath = opts.AssetsPath
+	s.TemplateDir = opts.TemplateDir
+	s.TemplateHtmlName = templateHtmlName
+	s.OutputDir = opts.OutputDir
+	s.UrlSuffix = suffix
+	s.Params = opts.Params
+	s.Addr = opts.Addr
+	s.CachePath = opts.CachePath
+	s.Concurrency = opts.Concurrency
+	s.KeepGoing = opts.KeepGoing
+	s.Strict = opts.Strict
+	s.LayoutBuilder, err = s.makeLayoutBuilder(opts.BaseUrl, opts.AssetsPath, mdLayoutDir, allHInfos)
+	return err
 }
 
-// テンプレートの組み上げ
+// テンプレートの組み上げ(Default, Initializeで初期化するサイト用)
+// Initializeのfnの実行中に呼ばれた場合は、設定中のサイトを対象とする
+//
+// Deprecated: 対象のサイトが呼び出し元から定まらないため、core.MakeDefaultLayoutBuilderを使用すること
 func MakeDefaultLayoutBuilder(baseUrl string, assetsPath string, mdLayoutDir string) (LayoutBuilder, error) {
+	return currentSite().MakeDefaultLayoutBuilder(baseUrl, assetsPath, mdLayoutDir)
+}
+
+// テンプレートの組み上げ
+func (s *Site) MakeDefaultLayoutBuilder(baseUrl string, assetsPath string, mdLayoutDir string) (LayoutBuilder, error) {
+	allHInfos, err := auto_link.NewMdAllHeaaderInfoWithSlug(s.MdPaths, s.slugFunc())
+	if err != nil {
+		return nil, err
+	}
+	return s.makeLayoutBuilder(baseUrl, assetsPath, mdLayoutDir, allHInfos)
+}
+
+// 収集済みの見出し情報を用いてテンプレートを組み上げる
+func (s *Site) makeLayoutBuilder(baseUrl string, assetsPath string, mdLayoutDir string, allHInfos auto_link.MdAllHeaaderInfo) (LayoutBuilder, error) {
 	// あらかじめレイアウト部品のビルドを実施
-	ginH, err := buildLayouts(assetsPath, mdLayoutDir)
+	s.layoutDir = mdLayoutDir
+	layoutH, err := s.buildLayouts(assetsPath, mdLayoutDir)
+	s.layoutDigest = layoutDigest(layoutH)
 
 	// そのほか、htmlへ埋め込む変数
-	ginH["base_url"] = baseUrl
-	ginH["assets_path"] = baseUrl + "/" + assetsPath
+	layoutH["base_url"] = baseUrl
+	layoutH["assets_path"] = baseUrl + "/" + assetsPath
+	layoutH["params"] = s.Params
 
-	allHInfos, _ := auto_link.NewMdAllHeaaderInfo(c.MdPaths)
+	s.pagesDigest = pagesDigest(allHInfos.Pages())
+	nav := auto_link.NewNav(baseUrl, allHInfos, s.UrlSuffix)
 
 	// 関数構築
+	// 並行して呼び出されるため、ページごとに新たなマップを返す
 	return func(metaData md_parse.MetaData, convertedHtml template.HTML) gin.H {
+		ginH := gin.H{}
+		for key, value := range layoutH {
+			ginH[key] = value
+		}
+		ginH["page"] = metaData
 		ginH["title"] = metaData.Title
 		ginH["overview"] = template.HTML(blackfriday.MarkdownCommon([]byte(metaData.Overview)))
-		ginH["breadcrumbs"] = auto_link.MakeBreadCrumbs(baseUrl, metaData.PageName, allHInfos, c.UrlSuffix)
+		ginH["breadcrumbs"] = auto_link.MakeBreadCrumbs(baseUrl, metaData.PageName, allHInfos, s.UrlSuffix)
 		for i := 1; i <= 6; i++ {
-			ginH["idlinks"+strconv.Itoa(i)] = auto_link.MakePageInnerPaths(baseUrl, metaData.PageName, i, allHInfos, c.UrlSuffix)
+			ginH["idlinks"+strconv.Itoa(i)] = auto_link.MakePageInnerPaths(baseUrl, metaData.PageName, i, allHInfos, s.UrlSuffix)
 		}
+		ginH["nav"] = nav.Tree(metaData.PageName)
+		ginH["prev"], ginH["next"] = nav.PrevNext(metaData.PageName)
+		ginH["toc"] = auto_link.MakeTOC(baseUrl, metaData.PageName, s.TOCMinDepth, s.TOCMaxDepth, allHInfos, s.UrlSuffix)
 		ginH["content"] = convertedHtml
 		return ginH
 	}, err
 }
 
+// 見出しのidを生成する関数。未指定の場合はGitHubと互換のslug
+func (s *Site) slugFunc() slug.Func {
+	if s.SlugFunc == nil {
+		return slug.GitHub
+	}
+	return s.SlugFunc
+}
+
 // ヘッダ、サイドバー、フッタをマークダウンからHTMLに変換する
-func buildLayouts(assetsPath string, mdLayoutDir string) (gin.H, error) {
+func (s *Site) buildLayouts(assetsPath string, mdLayoutDir string) (gin.H, error) {
 	layoutComponentPathes := map[string]string{
 		"header":  filepath.Join(mdLayoutDir, "_header.md"),
 		"sidebar": filepath.Join(mdLayoutDir, "_sidebar.md"),
@@ -147,87 +343,187 @@ func buildLayouts(assetsPath string, mdLayoutDir string) (gin.H, error) {
 	}
 
 	// html部品のマークダウンをhtml化
-	var err error
+	// 失敗した部品があっても、他の部品の変換は継続し全てのエラーを返す
+	report := &BuildReport{}
+	s.layoutWarnings = &BuildReport{}
 	ginH := gin.H{}
-	for key, path := range layoutComponentPathes {
+	for _, key := range []string{"header", "sidebar", "footer"} {
+		path := layoutComponentPathes[key]
 		// マークダウンのバイト列取得
-		bytes, readErr := os.ReadFile(path)
-		if readErr != nil {
-			err = readErr
+		bytes, err := os.ReadFile(path)
+		if err != nil {
+			report.add(err, path, StageLayout)
 			continue
 		}
 		// マークダウンにミドルウェア適用
-		if _, bytes, err = c.MdMiddlewareList.Apply(md_parse.MetaData{}, bytes); err != nil {
-			fmt.Println(err)
+		var metaData md_parse.MetaData
+		if metaData, bytes, err = s.MdMiddlewareList.Apply(md_parse.MetaData{BodyLine: 1}, bytes); err != nil {
+			report.add(err, path, StageLayout)
 			continue
 		}
+		s.layoutWarnings.addWarnings(path, metaData.Warnings, false)
 		htmlBytes := blackfriday.MarkdownCommon(bytes)
 		ginH[key] = template.HTML(htmlBytes)
 	}
-	return ginH, err
+	return ginH, report.err()
 }
 
 // マークダウンをHTMLへ変換
-func convertToHtml(mdFilePath string) (md_parse.MetaData, []byte, error) {
+func (s *Site) convertToHtml(mdFilePath string) (md_parse.MetaData, []byte, error) {
+	metaData, _, mdBytes, err := s.loadPage(mdFilePath)
+	if err != nil {
+		return metaData, []byte{}, err
+	}
+	return s.mdToHtml(mdFilePath, metaData, mdBytes)
+}
+
+// マークダウンを読み込み、メタデータの解析とマークダウンへのミドルウェア適用を行う
+// 読み込んだファイルの内容と、ミドルウェア適用後のマークダウンを返す
+func (s *Site) loadPage(mdFilePath string) (md_parse.MetaData, []byte, []byte, error) {
 	// マークダウンのバイト列取得
 	bytes, readErr := os.ReadFile(mdFilePath)
 	if readErr != nil {
-		return md_parse.MetaData{}, []byte{}, readErr
+		return md_parse.MetaData{}, nil, []byte{}, newPageError(mdFilePath, StageRead, readErr)
 	}
-	metaData, mdBytes, err := md_parse.ParseFileBytes(bytes)
+	pageName := strings.ReplaceAll(s.MdPaths.GetPageName(mdFilePath), "\\", "/")
+	metaData, mdBytes, err := md_parse.ParseFileBytesWithSchema(bytes, s.FrontMatterSchema.ForPage(pageName))
 	if err != nil {
-		return metaData, []byte{}, err
+		return metaData, bytes, []byte{}, newPageError(mdFilePath, StageFrontMatter, err)
 	}
 
 	// マークダウンにミドルウェア適用
-	if metaData, mdBytes, err = c.MdMiddlewareList.Apply(metaData, mdBytes); err != nil {
-		return metaData, []byte{}, err
+	if metaData, mdBytes, err = s.MdMiddlewareList.Apply(metaData, mdBytes); err != nil {
+		return metaData, bytes, []byte{}, newPageError(mdFilePath, StageMdMiddleware, err)
 	}
 
-	metaData.PageName = strings.ReplaceAll(c.MdPaths.GetPageName(mdFilePath), "\\", "/")
+	metaData.PageName = pageName
+	return metaData, bytes, mdBytes, nil
+}
 
-	// HTMLに変換の上ミドルウェア適用
-	return c.HtmlMiddlewareList.Apply(
+// ミドルウェア適用済みのマークダウンをHTMLに変換の上、HTMLへのミドルウェアを適用する
+func (s *Site) mdToHtml(mdFilePath string, metaData md_parse.MetaData, mdBytes []byte) (md_parse.MetaData, []byte, error) {
+	metaData, htmlBytes, err := s.HtmlMiddlewareList.Apply(
 		metaData,
 		blackfriday.MarkdownCommon(mdBytes),
 	)
+	if err != nil {
+		return metaData, []byte{}, newPageError(mdFilePath, StageHtmlMiddleware, err)
+	}
+	return metaData, htmlBytes, nil
 }
 
 // preview の場合はプレビュー用のサーバーを起動する
 func Build() error {
-	switch c.previewFlag {
-	case preview:
-		return RunPreviewServer()
-	case previewStatic:
-		return RunPreviewStatic()
-	}
-	return BuildStaticSite()
+	return defaultSite.Build()
 }
 
 // 静的サイトを出力
 func BuildStaticSite() error {
-	if !c.initialized {
+	return defaultSite.BuildStaticSite()
+}
+
+// 静的ファイル生成の上、プレビュー
+func RunPreviewStatic() error {
+	return defaultSite.RunPreviewStatic()
+}
+
+// プレビュー用サーバー起動
+func RunPreviewServer() error {
+	return defaultSite.RunPreviewServer()
+}
+
+// モードに応じたプレビュー用サーバーを起動し、ctxの終了により停止する
+func Serve(ctx context.Context) error {
+	return defaultSite.Serve(ctx)
+}
+
+// preview の場合はプレビュー用のサーバーを起動する
+func (s *Site) Build() error {
+	switch s.Mode {
+	case ModePreview:
+		return s.RunPreviewServer()
+	case ModePreviewStatic:
+		return s.RunPreviewStatic()
+	}
+	return s.BuildStaticSite()
+}
+
+// 静的サイトを出力
+func (s *Site) BuildStaticSite() error {
+	if !s.initialized {
 		return fmt.Errorf("Prease Call the function 'Default' or 'Initialize' beforehand.")
 	}
 
-	// 既に存在している場合、出力先ディレクトリを作り直す
-	_, err := os.Stat(c.OutputDir)
-	if !os.IsNotExist(err) {
-		if err = os.RemoveAll(c.OutputDir); err != nil {
-			return err
+	b, err := s.newIncrementalBuild()
+	if err != nil {
+		return err
+	}
+
+	// 前回の出力を再利用できない場合、出力先ディレクトリを作り直す
+	if !b.reusable() {
+		_, err = os.Stat(s.OutputDir)
+		if !os.IsNotExist(err) {
+			if err = os.RemoveAll(s.OutputDir); err != nil {
+				return err
+			}
 		}
 	}
 
-	if err = copyAssetsAll(); err != nil {
+	// ファイル単位のエラーは全て収集する
+	report := s.newBuildReport()
+	defer s.printWarnings(report)
+	if err = s.copyAssetsAll(b, report); err != nil {
+		return err
+	}
+	if err = s.outputHtmlAll(b, report); err != nil {
+		return err
+	}
+	if len(report.Errors) > 0 && !s.KeepGoing {
+		return report
+	}
+
+	if err = b.finish(); err != nil {
 		return err
 	}
-	return outputHtmlAll()
+	return report.err()
+}
+
+// レイアウト部品の警告を含めたビルドの結果を生成
+func (s *Site) newBuildReport() *BuildReport {
+	report := &BuildReport{}
+	if s.layoutWarnings != nil {
+		for _, w := range s.layoutWarnings.Warnings {
+			if s.Strict {
+				report.Errors = append(report.Errors, w)
+			} else {
+				report.Warnings = append(report.Warnings, w)
+			}
+		}
+	}
+	return report
+}
+
+// ビルドの警告を出力
+func (s *Site) printWarnings(report *BuildReport) {
+	if len(report.Warnings) == 0 {
+		return
+	}
+	fmt.Fprintln(s.logWriter(), report.WarningSummary())
+}
+
+// 警告、エラーの出力先
+func (s *Site) logWriter() io.Writer {
+	if s.Log != nil {
+		return s.Log
+	}
+	return os.Stderr
 }
 
 // アセッツのコピー
-func copyAssetsAll() error {
+// ファイル単位のエラーはreportへ追加し、処理を継続する
+func (s *Site) copyAssetsAll(b *incrementalBuild, report *BuildReport) error {
 	assetsPaths, err := access_md.NewMdPaths(
-		c.AssetsPath,
+		s.AssetsPath,
 		[]string{},
 		[]string{".css", ".js"},
 	)
@@ -238,133 +534,229 @@ func copyAssetsAll() error {
 
 	// outputDir/assetsへファイルをコピー
 	for _, path := range assetsPaths.GetAll() {
-		if err := copyAssets(assetsPaths, path); err != nil {
-			return err
+		if err := s.copyAssets(assetsPaths, path, b); err != nil {
+			b.discard(path)
+			report.add(err, path, StageAsset)
 		}
 	}
 	return nil
 }
 
-// 一つのファイルをコピー
-func copyAssets(assetsPaths access_md.MdPaths, filePath string) error {
-	assetsOutputDir := filepath.Join(c.OutputDir, c.AssetsPath)
-	if err := os.MkdirAll(filepath.Join(assetsOutputDir, assetsPaths.GetPageDir(filePath)), 0777); err != nil {
-		return err
-	}
-
+// 一つのファイルをコピー。前回のビルドから内容が変わっていない場合は何もしない
+func (s *Site) copyAssets(assetsPaths access_md.MdPaths, filePath string, b *incrementalBuild) error {
+	assetsOutputDir := filepath.Join(s.OutputDir, s.AssetsPath)
 	relPath, _ := filepath.Rel(assetsPaths.GetBaseDirPath(), filePath)
-	dest, err := os.Create(filepath.Join(assetsOutputDir, relPath))
+	outputPath := filepath.Join(assetsOutputDir, relPath)
+
+	contents, err := os.ReadFile(filePath)
 	if err != nil {
 		return err
 	}
+	if b.fresh(filePath, build_cache.Hash(contents), outputPath) {
+		return nil
+	}
 
-	src, err := os.Open(filePath)
-	if err != nil {
+	if err := os.MkdirAll(filepath.Join(assetsOutputDir, assetsPaths.GetPageDir(filePath)), 0777); err != nil {
 		return err
 	}
-
-	_, err = io.Copy(dest, src)
-	return err
+	return os.WriteFile(outputPath, contents, 0666)
 }
 
 // 配置されたmdよりすべてのHTMLファイルを出力する
-func outputHtmlAll() error {
-	// htmlテンプレート取得
-	t, err := template.New(c.TemplateHtmlName).
-		Funcs(passFuncToTemplate()).
-		ParseFiles(filepath.Join(c.TemplateDir, c.TemplateHtmlName))
+// ページ単位のエラーはreportへ追加し、全てのページを処理する
+// エラーが存在する場合、KeepGoingでなければ一つも書き込まない
+func (s *Site) outputHtmlAll(b *incrementalBuild, report *BuildReport) error {
+	t, err := s.loadTemplate()
 	if err != nil {
 		return err
 	}
 
-	for _, mdPath := range c.MdPaths.GetAll() {
-		if err := outputHtml(t, mdPath); err != nil {
-			return err
+	// 結果はワーカー数によらずページの順序で判定する
+	mdPaths := s.MdPaths.GetAll()
+	results := make([]pageOutput, len(mdPaths))
+	s.forEachParallel(len(mdPaths), func(i int) {
+		results[i] = s.renderOutput(t, mdPaths[i], b)
+	})
+	for _, result := range results {
+		if result.err != nil {
+			report.add(result.err, result.mdPath, StageRead)
+		}
+		report.addWarnings(result.mdPath, result.warnings, s.Strict)
+		if result.failed(s.Strict) {
+			b.discard(result.mdPath)
+		}
+	}
+	if len(report.Errors) > 0 && !s.KeepGoing {
+		return nil
+	}
+
+	for _, result := range results {
+		if result.failed(s.Strict) || result.html == nil {
+			continue
+		}
+		if err := s.writeOutput(result); err != nil {
+			b.discard(result.mdPath)
+			report.add(err, result.outputPath, StageWrite)
 		}
 	}
 	return nil
 }
 
-// HTMLファイルを出力
-func outputHtml(t *template.Template, mdPath string) error {
-	metaData, htmlBytes, err := convertToHtml(mdPath)
-	if err != nil {
-		return err
+// 同時に処理するページ数
+func (s *Site) concurrency() int {
+	if s.Concurrency > 0 {
+		return s.Concurrency
 	}
+	return runtime.GOMAXPROCS(0)
+}
 
-	var buf bytes.Buffer
-	if err = t.Execute(&buf, c.LayoutBuilder(metaData, template.HTML(htmlBytes))); err != nil {
-		return err
+// 0からn-1までの各番号について、最大 s.concurrency() 個のワーカーでfnを実行する
+func (s *Site) forEachParallel(n int, fn func(i int)) {
+	workers := s.concurrency()
+	if workers > n {
+		workers = n
 	}
 
-	if err = os.MkdirAll(filepath.Join(c.OutputDir, c.MdPaths.GetPageDir(mdPath)), 0777); err != nil {
-		return err
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indexes <- i
 	}
-	return os.WriteFile(filepath.Join(c.OutputDir, c.MdPaths.GetPageName(mdPath)+".html"), buf.Bytes(), 0777)
+	close(indexes)
+	wg.Wait()
 }
 
-// 静的ファイル生成の上、プレビュー
-func RunPreviewStatic() error {
-	if !c.initialized {
-		return fmt.Errorf("Prease Call the function 'Default' or 'Initialize' beforehand.")
+// htmlテンプレート取得
+func (s *Site) loadTemplate() (*template.Template, error) {
+	t, err := template.New(s.TemplateHtmlName).
+		Funcs(passFuncToTemplate()).
+		ParseFiles(s.templatePath())
+	if err != nil {
+		return nil, newTemplateError(s.templatePath(), "", err)
 	}
+	return t, nil
+}
 
-	if err := BuildStaticSite(); err != nil {
-		return err
+// htmlテンプレートのファイルパス
+func (s *Site) templatePath() string {
+	return filepath.Join(s.TemplateDir, s.TemplateHtmlName)
+}
+
+// 出力する一つのページ
+type pageOutput struct {
+	mdPath     string
+	outputPath string
+	// 前回のビルドから入力が変わっていない場合はnil
+	html     []byte
+	warnings []md_parse.Warning
+	err      error
+}
+
+// 出力に失敗したか。strictの場合は警告のあるページも失敗とする
+func (r pageOutput) failed(strict bool) bool {
+	return r.err != nil || (strict && len(r.warnings) > 0)
+}
+
+// HTMLファイルの内容を生成。前回のビルドから入力が変わっていない場合は生成しない
+func (s *Site) renderOutput(t *template.Template, mdPath string, b *incrementalBuild) pageOutput {
+	outputPath := filepath.Join(s.OutputDir, s.MdPaths.GetPageName(mdPath)+".html")
+	result := pageOutput{mdPath: mdPath, outputPath: outputPath}
+
+	metaData, source, mdBytes, err := s.loadPage(mdPath)
+	if err != nil {
+		result.err = err
+		return result
 	}
+	result.warnings = metaData.Warnings
 
-	gin.SetMode(gin.ReleaseMode)
-	router := gin.Default()
-	router.Static("/", c.OutputDir)
+	// リンク先の変化はミドルウェア適用後のマークダウンに現れる
+	if b.fresh(mdPath, b.pageKey([]byte(mdPath), source, mdBytes), outputPath) {
+		return result
+	}
 
-	// run
-	router.Run(":8080")
-	return nil
+	result.html, result.err = s.renderLoadedPage(t, mdPath, metaData, mdBytes)
+	return result
 }
 
-// プレビュー用サーバー起動
-func RunPreviewServer() error {
-	if !c.initialized {
-		return fmt.Errorf("Prease Call the function 'Default' or 'Initialize' beforehand.")
+// HTMLファイルを出力
+func (s *Site) writeOutput(result pageOutput) error {
+	if err := os.MkdirAll(filepath.Join(s.OutputDir, s.MdPaths.GetPageDir(result.mdPath)), 0777); err != nil {
+		return err
 	}
+	return os.WriteFile(result.outputPath, result.html, 0777)
+}
 
-	gin.SetMode(gin.ReleaseMode)
-	router := gin.Default()
-	router.Static("/"+c.AssetsPath, c.AssetsPath)
-	router.LoadHTMLGlob(c.TemplateDir + "/*.html")
-	router.SetFuncMap(passFuncToTemplate())
+// マークダウンを変換し、テンプレートへ埋め込んだHTMLと、ページの警告を返す
+func (s *Site) renderPage(t *template.Template, mdPath string) ([]byte, []md_parse.Warning, error) {
+	metaData, _, mdBytes, err := s.loadPage(mdPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	htmlBytes, err := s.renderLoadedPage(t, mdPath, metaData, mdBytes)
+	return htmlBytes, metaData.Warnings, err
+}
 
-	// マークダウンのファイル配置よりroute作成
-	for _, mdPath := range c.MdPaths.GetAll() {
-		handler := makePreviewHandler(mdPath)
-		pagename := c.MdPaths.GetPageName(mdPath)
-		router.GET("/"+pagename, handler)
-		if pagename == "index" {
-			router.GET("/", handler)
-		}
+// loadPageで読み込んだページを変換し、テンプレートへ埋め込んだHTMLを返す
+func (s *Site) renderLoadedPage(t *template.Template, mdPath string, metaData md_parse.MetaData, mdBytes []byte) ([]byte, error) {
+	metaData, htmlBytes, err := s.mdToHtml(mdPath, metaData, mdBytes)
+	if err != nil {
+		return nil, err
 	}
 
-	// run
-	router.Run(":8080")
-	return nil
+	var buf bytes.Buffer
+	if err = t.Execute(&buf, s.LayoutBuilder(metaData, template.HTML(htmlBytes))); err != nil {
+		return nil, newTemplateError(s.templatePath(), mdPath, err)
+	}
+	return buf.Bytes(), nil
 }
 
-// ルーティングのハンドラ作成
-func makePreviewHandler(mdPath string) func(con *gin.Context) {
-	metaData, htmlBytes, err := convertToHtml(mdPath)
-	return func(con *gin.Context) {
+// ファイルの出力は行わず、全てのページがHTMLへ変換可能であるか確認する
+func (s *Site) Check() error {
+	if !s.initialized {
+		return fmt.Errorf("Prease Call the function 'Default' or 'Initialize' beforehand.")
+	}
+
+	t, err := s.loadTemplate()
+	if err != nil {
+		return err
+	}
+
+	mdPaths := s.MdPaths.GetAll()
+	errs := make([]error, len(mdPaths))
+	warnings := make([][]md_parse.Warning, len(mdPaths))
+	s.forEachParallel(len(mdPaths), func(i int) {
+		_, warnings[i], errs[i] = s.renderPage(t, mdPaths[i])
+	})
+
+	report := s.newBuildReport()
+	defer s.printWarnings(report)
+	for i, err := range errs {
 		if err != nil {
-			fmt.Println(err)
-			con.AbortWithStatus(http.StatusInternalServerError)
-			return
+			report.add(err, mdPaths[i], StageRead)
 		}
+		report.addWarnings(mdPaths[i], warnings[i], s.Strict)
+	}
+	return report.err()
+}
 
-		con.HTML(
-			http.StatusOK,
-			c.TemplateHtmlName,
-			c.LayoutBuilder(metaData, template.HTML(htmlBytes)),
-		)
+// 出力ディレクトリのHTMLを解析し、リンク切れ、存在しないアンカー、存在しないアセッツを返す
+func (s *Site) CheckLinks() ([]link_check.Problem, error) {
+	if _, err := os.Stat(s.OutputDir); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("output directory %q does not exist. build the site beforehand", s.OutputDir)
+		}
+		return nil, err
 	}
+	return link_check.Check(s.OutputDir, s.BaseUrl)
 }
 
 func passFuncToTemplate() template.FuncMap {
@@ -375,5 +767,8 @@ func passFuncToTemplate() template.FuncMap {
 		"safeHTML": func(s string) template.HTML {
 			return template.HTML(s)
 		},
+		"renderTOC": func(nodes []*auto_link.TOCNode) template.HTML {
+			return template.HTML(auto_link.RenderTOC(nodes))
+		},
 	}
 }
@@ -0,0 +1,284 @@
+package ssgen
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TwilightUncle/ssgen/helpers/testing_helper"
+)
+
+// ナビゲーションのページ名を入れ子の[]で並べるテンプレート
+const testTemplate = `{{define "nav"}}{{range .}}[{{.PageName}}{{template "nav" .Children}}]{{end}}{{end}}` +
+	`<html><body><nav>{{template "nav" .nav}}</nav>{{.content}}</body></html>`
+
+// テスト用のサイトのファイルを作成し、その設定を返す
+// pagesはマークダウンのディレクトリからの相対パスと内容
+func makeTestSite(t *testing.T, pages map[string]string) Options {
+	dir := t.TempDir()
+	files := []testing_helper.TestFileData{
+		{Path: filepath.Join(dir, "md", "layout", "_header.md"), Contents: []byte("# header\n")},
+		{Path: filepath.Join(dir, "md", "layout", "_sidebar.md"), Contents: []byte("sidebar\n")},
+		{Path: filepath.Join(dir, "md", "layout", "_footer.md"), Contents: []byte("footer\n")},
+		{Path: filepath.Join(dir, "assets", "style.css"), Contents: []byte("body {}\n")},
+		{Path: filepath.Join(dir, "template", "index.html"), Contents: []byte(testTemplate)},
+	}
+	for name, contents := range pages {
+		files = append(files, testing_helper.TestFileData{Path: filepath.Join(dir, "md", name), Contents: []byte(contents)})
+	}
+	testing_helper.MakeTestFiles(dir, files, t)
+
+	return Options{
+		MdBaseDir:   filepath.Join(dir, "md"),
+		AssetsPath:  filepath.Join(dir, "assets"),
+		TemplateDir: filepath.Join(dir, "template"),
+		OutputDir:   filepath.Join(dir, "public"),
+	}
+}
+
+func writeTestFile(t *testing.T, path string, contents string) {
+	if err := os.WriteFile(path, []byte(contents), 0666); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestFile(t *testing.T, path string) string {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(contents)
+}
+
+// 出力先のHTMLファイルの一覧(出力先からの相対パス)
+func outputPages(t *testing.T, outputDir string) []string {
+	pages := []string{}
+	err := filepath.Walk(outputDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) != ".html" {
+			return err
+		}
+		rel, _ := filepath.Rel(outputDir, path)
+		pages = append(pages, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	sort.Strings(pages)
+	return pages
+}
+
+func TestNewSitesAreIndependent(t *testing.T) {
+	optsA := makeTestSite(t, map[string]string{"index.md": "# Alpha\n\n[{a}]\n", "a.md": "# A\n"})
+	optsA.BaseUrl = "/alpha"
+	optsB := makeTestSite(t, map[string]string{"index.md": "# Beta\n\n[{b}]\n", "b.md": "# B\n"})
+	optsB.BaseUrl = "/beta"
+
+	siteA, err := New(optsA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	siteB, err := New(optsB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 一方の生成が他方の設定を変更しない
+	if siteA.BaseUrl != "/alpha" || siteA.OutputDir != optsA.OutputDir || len(siteA.MdPaths.GetAll()) != 2 {
+		t.Errorf("site A was modified: %+v", siteA)
+	}
+	if defaultSite.initialized {
+		t.Error("New initialized the default site")
+	}
+
+	for _, site := range []*Site{siteA, siteB} {
+		if err := site.BuildStaticSite(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if pages := outputPages(t, optsA.OutputDir); !reflect.DeepEqual(pages, []string{"a.html", "index.html"}) {
+		t.Errorf("Actual [%s], want site A pages", strings.Join(pages, ", "))
+	}
+	if pages := outputPages(t, optsB.OutputDir); !reflect.DeepEqual(pages, []string{"b.html", "index.html"}) {
+		t.Errorf("Actual [%s], want site B pages", strings.Join(pages, ", "))
+	}
+	// リンクはそれぞれのサイトの設定、ページから生成する
+	if index := readTestFile(t, filepath.Join(optsA.OutputDir, "index.html")); !strings.Contains(index, `href="/alpha/a.html"`) {
+		t.Errorf("Actual [%s], want link to /alpha", index)
+	}
+	if index := readTestFile(t, filepath.Join(optsB.OutputDir, "index.html")); !strings.Contains(index, `href="/beta/b.html"`) {
+		t.Errorf("Actual [%s], want link to /beta", index)
+	}
+}
+
+// テストの間のみ、defaultSiteを未初期化のサイトに置き換える
+func resetDefaultSite(t *testing.T) {
+	saved := defaultSite
+	defaultSite = &Site{}
+	t.Cleanup(func() { defaultSite = saved })
+}
+
+// fnが終了しない場合は失敗とする
+func runWithTimeout(t *testing.T, name string, fn func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s did not return", name)
+	}
+}
+
+func TestMakeDefaultLayoutBuilderDuringSetup(t *testing.T) {
+	resetDefaultSite(t)
+	opts := makeTestSite(t, map[string]string{"index.md": "# Home\n"})
+	opts.Mode = ModePreview
+	layoutDir := filepath.Join(opts.MdBaseDir, "layout")
+
+	// パッケージ関数でレイアウトを組み上げる
+	err := Initialize(func(core *Core) error {
+		if err := core.setupDefault(opts); err != nil {
+			return err
+		}
+		var err error
+		core.LayoutBuilder, err = MakeDefaultLayoutBuilder(opts.BaseUrl, opts.AssetsPath, layoutDir)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := defaultSite.layoutDigest
+	if defaultSite.layoutDir != layoutDir || digest == "" {
+		t.Errorf("Actual [%q %q], want layout of defaultSite", defaultSite.layoutDir, digest)
+	}
+
+	// 再構築では新たなサイトを対象とし、defaultSiteは変更しない
+	writeTestFile(t, filepath.Join(layoutDir, "_header.md"), "# changed\n")
+	rebuilt, err := defaultSite.rebuild()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if defaultSite.layoutDigest != digest {
+		t.Error("defaultSite was modified by the rebuild")
+	}
+	if rebuilt.layoutDir != layoutDir || rebuilt.layoutDigest == digest {
+		t.Errorf("Actual [%q %q], want layout of the rebuilt site", rebuilt.layoutDir, rebuilt.layoutDigest)
+	}
+	if currentSite() != defaultSite {
+		t.Error("want defaultSite after setup")
+	}
+}
+
+func TestNewNested(t *testing.T) {
+	resetDefaultSite(t)
+	outer := makeTestSite(t, map[string]string{"index.md": "# Outer\n"})
+	inner := makeTestSite(t, map[string]string{"index.md": "# Inner\n"})
+
+	// 設定処理の中で別のサイトを生成できる
+	var innerSite *Site
+	setup := func(core *Core) error {
+		var err error
+		innerSite, err = New(inner)
+		return err
+	}
+	runWithTimeout(t, "New in New", func() {
+		outer.Setup = setup
+		if _, err := New(outer); err != nil {
+			t.Error(err)
+		}
+	})
+	runWithTimeout(t, "New in Initialize", func() {
+		err := Initialize(func(core *Core) error {
+			if err := core.setupDefault(outer); err != nil {
+				return err
+			}
+			return setup(core)
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	})
+	if innerSite == nil || innerSite.MdPaths.GetBaseDirPath() != inner.MdBaseDir {
+		t.Errorf("Actual [%v], want site of %s", innerSite, inner.MdBaseDir)
+	}
+
+	// Initializeのfnの中では、Initializeによるサイトの設定は待たずにエラーとなる
+	resetDefaultSite(t)
+	runWithTimeout(t, "Initialize in Initialize", func() {
+		var nestedErrs []error
+		err := Initialize(func(core *Core) error {
+			nestedErrs = append(nestedErrs, Initialize(func(core *Core) error { return nil }))
+			_, err := core.rebuild()
+			nestedErrs = append(nestedErrs, err)
+			return nil
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		for _, err := range nestedErrs {
+			if err == nil {
+				t.Error("want error for nested setup")
+			}
+		}
+	})
+}
+
+func TestNewConcurrent(t *testing.T) {
+	resetDefaultSite(t)
+	blocked := makeTestSite(t, map[string]string{"index.md": "# Blocked\n"})
+	other := makeTestSite(t, map[string]string{"index.md": "# Other\n"})
+
+	// 設定処理の終わらないサイトがあっても、他のサイトは生成できる
+	started := make(chan struct{})
+	release := make(chan struct{})
+	blocked.Setup = func(site *Site) error {
+		close(started)
+		<-release
+		return nil
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := New(blocked)
+		errCh <- err
+	}()
+	runWithTimeout(t, "New", func() { <-started })
+	runWithTimeout(t, "concurrent New", func() {
+		site, err := New(other)
+		if err != nil {
+			t.Error(err)
+		} else if site.MdPaths.GetBaseDirPath() != other.MdBaseDir {
+			t.Errorf("Actual [%s], want [%s]", site.MdPaths.GetBaseDirPath(), other.MdBaseDir)
+		}
+	})
+	// パッケージ関数はNewで設定中のサイトを対象としない
+	if currentSite() != defaultSite {
+		t.Error("want defaultSite during New")
+	}
+	close(release)
+	if err := <-errCh; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSiteMakeDefaultLayoutBuilderError(t *testing.T) {
+	opts := makeTestSite(t, map[string]string{"index.md": "# Home\n", "a.md": "# A\n"})
+	site, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 収集済みのページが読み込めない場合はエラー
+	if err := os.Remove(filepath.Join(opts.MdBaseDir, "a.md")); err != nil {
+		t.Fatal(err)
+	}
+	builder, err := site.MakeDefaultLayoutBuilder(opts.BaseUrl, opts.AssetsPath, filepath.Join(opts.MdBaseDir, "layout"))
+	if err == nil || builder != nil {
+		t.Errorf("Actual [%v], want error", err)
+	}
+}